@@ -3,6 +3,8 @@ package resingo
 import (
 	"encoding/json"
 	"errors"
+
+	"github.com/guregu/null"
 )
 
 //AgentReboot reboots the device
@@ -35,3 +37,41 @@ func AgentReboot(ctx *Context, devID, appID int64, force bool) error {
 	return nil
 
 }
+
+//AgentState is the state of a device as reported by its supervisor.
+type AgentState struct {
+	APIPort           int      `json:"api_port"`
+	IPAddress         string   `json:"ip_address"`
+	Commit            string   `json:"commit"`
+	Status            string   `json:"status"`
+	DownloadProgress  null.Int `json:"download_progress"`
+	OSVersion         string   `json:"os_version"`
+	SupervisorVersion string   `json:"supervisor_version"`
+	UpdatePending     bool     `json:"update_pending"`
+	UpdateDownloaded  bool     `json:"update_downloaded"`
+	UpdateFailed      bool     `json:"update_failed"`
+}
+
+//AgentDeviceState returns the state of the device with the given uuid from its
+//supervisor. The device must be online.
+func AgentDeviceState(ctx *Context, uuid string) (*AgentState, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := apiEndpoint + "/supervisor/v1/device"
+	data := make(map[string]interface{})
+	data["uuid"] = uuid
+	data["method"] = "GET"
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "POST", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	s := &AgentState{}
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -23,17 +23,20 @@ type Application struct {
 	DeviceType string `json:"device_type"`
 	User       User   `json:"user"`
 	Commit     string `json:"commit"`
+
+	// TrackLatest is false when the application is pinned to a release.
+	TrackLatest bool `json:"should_track_latest_release"`
 }
 
 //AppGetAll retrieves all applications that belog to the user in the given
-//context.
+//context, and match all filters.
 //
 // For this to work, the context should be authorized, probably using the Login
 // function.
-func AppGetAll(ctx *Context) ([]*Application, error) {
+func AppGetAll(ctx *Context, filters ...Filter) ([]*Application, error) {
 	h := authHeader(ctx.Config.AuthToken)
 	uri := ctx.Config.APIEndpoint("application")
-	b, err := doJSON(ctx, "GET", uri, h, nil, nil)
+	b, err := doJSON(ctx, "GET", uri, h, filterParams(filters), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +90,7 @@ func do(ctx *Context, method, uri string, header http.Header,
 		req.Header = header
 	}
 	req.Header = header
-	resp, err := ctx.Client.Do(req)
+	resp, err := send(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -139,13 +142,23 @@ func AppGetByID(ctx *Context, id int64) (*Application, error) {
 	return nil, errors.New("application not found")
 }
 
-//AppCreate creates a new application with the given name
+//AppCreate creates a new application with the given name. The device type typ
+//can be any slug or alias supported by resin, it is validated against the
+//DeviceTypeRegistry before the application is created.
 func AppCreate(ctx *Context, name string, typ DeviceType) (*Application, error) {
+	reg, err := DevTypeGetRegistry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slug, err := reg.Resolve(string(typ))
+	if err != nil {
+		return nil, err
+	}
 	h := authHeader(ctx.Config.AuthToken)
 	uri := ctx.Config.APIEndpoint("application")
 	data := make(map[string]interface{})
 	data["app_name"] = name
-	data["device_type"] = typ.String()
+	data["device_type"] = slug.String()
 	body, err := marhsalReader(data)
 	if err != nil {
 		return nil, err
@@ -192,3 +205,108 @@ func AppGetAPIKey(ctx *Context, name string) ([]byte, error) {
 	uri := "https://api.resin.io/" + end
 	return doJSON(ctx, "POST", uri, h, nil, nil)
 }
+
+//Collaborator is a user who has access to an application.
+type Collaborator struct {
+	ID          int64 `json:"id"`
+	User        User  `json:"user"`
+	Application struct {
+		ID       int64 `json:"__id"`
+		Metadata struct {
+			URI string `json:"uri"`
+		} `json:"__deferred"`
+	} `json:"application"`
+	Metadata struct {
+		URI  string `json:"uri"`
+		Type string `json:"type"`
+	} `json:"__metadata"`
+}
+
+// patches the application with the given id and returns the updated
+// application.
+func appUpdate(ctx *Context, id int64, data map[string]interface{}) (*Application, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(fmt.Sprintf("application(%d)", id))
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "PATCH", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) != "OK" {
+		return nil, errors.New("bad response")
+	}
+	return AppGetByID(ctx, id)
+}
+
+//AppRename renames the application with the given id to newName.
+func AppRename(ctx *Context, id int64, newName string) (*Application, error) {
+	data := make(map[string]interface{})
+	data["app_name"] = newName
+	return appUpdate(ctx, id, data)
+}
+
+//AppSetCommit sets the commit that devices of the application with the given
+//id should be running. This can be used to pin the application to a
+//release.
+func AppSetCommit(ctx *Context, id int64, commit string) (*Application, error) {
+	data := make(map[string]interface{})
+	data["commit"] = commit
+	return appUpdate(ctx, id, data)
+}
+
+//AppSetDeviceType changes the device type of the application with the given
+//id. The device type is validated against the DeviceTypeRegistry.
+func AppSetDeviceType(ctx *Context, id int64, typ DeviceType) (*Application, error) {
+	reg, err := DevTypeGetRegistry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slug, err := reg.Resolve(string(typ))
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{})
+	data["device_type"] = slug.String()
+	return appUpdate(ctx, id, data)
+}
+
+//AppRestart restarts the application container on all devices of the
+//application with the given id.
+func AppRestart(ctx *Context, id int64) error {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := apiEndpoint + fmt.Sprintf("/application/%d/restart", id)
+	b, err := doJSON(ctx, "POST", uri, h, nil, nil)
+	if err != nil {
+		return err
+	}
+	if string(b) != "OK" {
+		return errors.New("bad response")
+	}
+	return nil
+}
+
+//AppGetCollaborators returns the users who have access to the application
+//with the given id.
+func AppGetCollaborators(ctx *Context, id int64) ([]*Collaborator, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("user__is_member_of__application")
+	params := make(url.Values)
+	params.Set("filter", "application")
+	params.Set("eq", fmt.Sprint(id))
+	params.Set("expand", "user")
+	b, err := doJSON(ctx, "GET", uri, h, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res = struct {
+		D []*Collaborator `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.D, nil
+}
@@ -1,8 +1,12 @@
 package resingo
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -63,6 +67,32 @@ func TestApplication(t *testing.T) {
 			testAppAPIKey(ctx, ts, a.name)
 		}
 	})
+	t.Run("Rename", func(ts *testing.T) {
+		name := applications[1].name + "_renamed"
+		app, err := AppRename(ctx, applications[1].app.ID, name)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if app.Name != name {
+			ts.Errorf("expected %s got %s", name, app.Name)
+		}
+		applications[1].name = name
+	})
+	t.Run("SetDeviceType", func(ts *testing.T) {
+		app, err := AppSetDeviceType(ctx, applications[1].app.ID, RaspberryPi3)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if app.DeviceType != RaspberryPi3.String() {
+			ts.Errorf("expected %s got %s", RaspberryPi3, app.DeviceType)
+		}
+	})
+	t.Run("Collaborators", func(ts *testing.T) {
+		_, err := AppGetCollaborators(ctx, applications[0].app.ID)
+		if err != nil {
+			ts.Fatal(err)
+		}
+	})
 	env := []struct {
 		key, value string
 	}{
@@ -178,3 +208,67 @@ func testAppAPIKey(ctx *Context, t *testing.T, name string) {
 	}
 	fmt.Println(string(b))
 }
+
+func TestAppMutators(t *testing.T) {
+	var (
+		method, path, body string
+		restartReply       = "OK"
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			b, _ := ioutil.ReadAll(r.Body)
+			method, path, body = r.Method, r.URL.Path, string(b)
+		}
+		switch {
+		case r.Method == "PATCH" && r.URL.Path == "/v1/application(7)":
+			fmt.Fprint(w, "OK")
+		case r.Method == "GET" && r.URL.Path == "/v1/application(7)":
+			fmt.Fprint(w, `{"d":[{"id":7,"app_name":"fleet","commit":"abc"}]}`)
+		case r.Method == "POST" && r.URL.Path == "/application/7/restart":
+			fmt.Fprint(w, restartReply)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	ctx := &Context{
+		Client: &http.Client{Transport: &rewriteTransport{target: target}},
+		Config: &Config{ResinEndpoint: apiEndpoint},
+	}
+	t.Run("SetCommit", func(ts *testing.T) {
+		app, err := AppSetCommit(ctx, 7, "abc")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if method != "PATCH" || path != "/v1/application(7)" {
+			ts.Errorf("expected PATCH /v1/application(7) got %s %s", method, path)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &data); err != nil {
+			ts.Fatal(err)
+		}
+		if len(data) != 1 || data["commit"] != "abc" {
+			ts.Errorf("expected only the commit in the body got %s", body)
+		}
+		if app.ID != 7 || app.Commit != "abc" {
+			ts.Errorf("expected the updated application got %+v", app)
+		}
+	})
+	t.Run("Restart", func(ts *testing.T) {
+		err := AppRestart(ctx, 7)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if method != "POST" || path != "/application/7/restart" {
+			ts.Errorf("expected POST /application/7/restart got %s %s", method, path)
+		}
+		restartReply = "nope"
+		if err = AppRestart(ctx, 7); err == nil {
+			ts.Error("expected an error for a bad response")
+		}
+		if err = AppRestart(ctx, 8); err == nil {
+			ts.Error("expected an error for a missing application")
+		}
+	})
+}
@@ -0,0 +1,357 @@
+package resingo
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// default values used by the CachingClient when CacheOptions leaves them
+// empty.
+const (
+	DefaultCacheTTL        = 30 * time.Second
+	DefaultCacheMaxEntries = 512
+	DefaultCacheMaxBytes   = 16 << 20
+)
+
+//CacheOptions configures the CachingClient.
+type CacheOptions struct {
+	// MaxEntries is the maximum number of responses kept in the cache. The
+	// least recently used responses are evicted when the cache is full.
+	MaxEntries int
+
+	// MaxBytes is the maximum size of the bodies of the responses kept in the
+	// cache. Responses larger than it are never cached.
+	MaxBytes int64
+
+	// Endpoints are the base urls of the APIs whose responses are cached, it
+	// defaults to the resin API. Responses of other hosts, like the images
+	// served by the image maker, are never cached.
+	Endpoints []string
+
+	// DefaultTTL is how long a response is kept for resources that are not in
+	// TTL.
+	DefaultTTL time.Duration
+
+	// TTL overrides DefaultTTL per resource. The resource is the name of the
+	// API resource like device, application or environment_variable. A
+	// negative TTL disables caching for the resource.
+	TTL map[string]time.Duration
+}
+
+//CacheStats are the statistics of a CachingClient.
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Evictions     int64
+	Invalidations int64
+	Entries       int
+	Bytes         int64
+}
+
+//CachingClient is a HTTPClient that caches successful GET responses of the API.
+//
+// Any other request that goes through the client is considered a mutation, and
+// invalidates all cached responses of the resource it was sent to, both before
+// it is sent and after its response is received. Requests for a range of the
+// body are never cached. Responses
+// are cached per Authorization header, so the client can safely be shared by
+// contexts authorized by different users.
+//
+//	ctx := &resingo.Context{
+//		Client: resingo.NewCachingClient(&http.Client{}, nil),
+//		Config: config,
+//	}
+type CachingClient struct {
+	client HTTPClient
+	opts   CacheOptions
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	stats CacheStats
+	size  int64
+	now   func() time.Time
+
+	// incremented by every invalidation, responses of requests sent before an
+	// invalidation are not cached.
+	gen uint64
+}
+
+type cacheEntry struct {
+	key string
+
+	// the resource of the request and those named in its query, like the
+	// expanded ones.
+	resources map[string]bool
+	expires   time.Time
+	status    int
+	header    http.Header
+	body      []byte
+}
+
+//NewCachingClient returns a CachingClient which caches responses of client.
+//Default options are used when opts is nil.
+func NewCachingClient(client HTTPClient, opts *CacheOptions) *CachingClient {
+	c := &CachingClient{
+		client: client,
+		ll:     list.New(),
+		items:  make(map[string]*list.Element),
+	}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.MaxEntries <= 0 {
+		c.opts.MaxEntries = DefaultCacheMaxEntries
+	}
+	if c.opts.DefaultTTL == 0 {
+		c.opts.DefaultTTL = DefaultCacheTTL
+	}
+	if c.opts.MaxBytes <= 0 {
+		c.opts.MaxBytes = DefaultCacheMaxBytes
+	}
+	if len(c.opts.Endpoints) == 0 {
+		c.opts.Endpoints = []string{apiEndpoint}
+	}
+	return c
+}
+
+//Do implements HTTPClient.
+func (c *CachingClient) Do(req *http.Request) (*http.Response, error) {
+	resource := APIResource(req.URL)
+	if req.Method != "GET" {
+		c.Invalidate(resource)
+		resp, err := c.client.Do(req)
+		c.Invalidate(resource)
+		return resp, err
+	}
+	ttl := c.ttl(resource)
+	if ttl < 0 || !c.cacheable(req) {
+		return c.client.Do(req)
+	}
+	key := req.Header.Get("Authorization") + " " + req.URL.String()
+	if e := c.get(key); e != nil {
+		return e.response(req), nil
+	}
+	gen := c.generation()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBytes+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(b)) > c.opts.MaxBytes {
+		// too large to be cached, the caller reads the rest of the body.
+		resp.Body = &struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	e := &cacheEntry{
+		key:       key,
+		resources: queryResources(req.URL),
+		expires:   c.clock().Add(ttl),
+		status:    resp.StatusCode,
+		header:    resp.Header,
+		body:      b,
+	}
+	e.resources[resource] = true
+	c.add(e, gen)
+	return e.response(req), nil
+}
+
+// reports whether the response of the GET request req may be cached.
+func (c *CachingClient) cacheable(req *http.Request) bool {
+	if req.Header.Get("Range") != "" {
+		return false
+	}
+	uri := req.URL.String()
+	for _, v := range c.opts.Endpoints {
+		v = strings.TrimSuffix(v, "/")
+		if uri == v || strings.HasPrefix(uri, v+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+var identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// returns the names in the query of u, which include the resources it expands
+// and filters on.
+func queryResources(u *url.URL) map[string]bool {
+	m := make(map[string]bool)
+	q, err := url.QueryUnescape(u.RawQuery)
+	if err != nil {
+		q = u.RawQuery
+	}
+	for _, v := range identRe.FindAllString(q, -1) {
+		m[v] = true
+	}
+	return m
+}
+
+//Post implements HTTPClient. Posts are never cached, and invalidate the
+//resource they are sent to.
+func (c *CachingClient) Post(uri string, bodyTyp string, body io.Reader) (*http.Response, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return c.client.Post(uri, bodyTyp, body)
+	}
+	resource := APIResource(u)
+	c.Invalidate(resource)
+	resp, err := c.client.Post(uri, bodyTyp, body)
+	c.Invalidate(resource)
+	return resp, err
+}
+
+//Invalidate removes all cached responses for the given resource. This
+//includes responses of other resources which were expanded with, or filtered on
+//the resource.
+func (c *CachingClient) Invalidate(resource string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*cacheEntry)
+		if e.resources[resource] {
+			c.remove(el)
+			c.stats.Invalidations++
+		}
+		el = next
+	}
+}
+
+//Purge removes all cached responses.
+func (c *CachingClient) Purge() {
+	c.mu.Lock()
+	c.gen++
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+	c.mu.Unlock()
+}
+
+//Stats returns the cache statistics.
+func (c *CachingClient) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.ll.Len()
+	s.Bytes = c.size
+	return s
+}
+
+func (c *CachingClient) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *CachingClient) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.clock().Before(e.expires) {
+		c.remove(el)
+		c.stats.Misses++
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	return e
+}
+
+// adds e unless the cache was invalidated since generation gen.
+func (c *CachingClient) add(e *cacheEntry, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	if el, ok := c.items[e.key]; ok {
+		c.remove(el)
+	}
+	c.items[e.key] = c.ll.PushFront(e)
+	c.size += int64(len(e.body))
+	for c.ll.Len() > c.opts.MaxEntries || c.size > c.opts.MaxBytes {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *CachingClient) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	c.size -= int64(len(e.body))
+}
+
+func (c *CachingClient) ttl(resource string) time.Duration {
+	if t, ok := c.opts.TTL[resource]; ok {
+		return t
+	}
+	return c.opts.DefaultTTL
+}
+
+func (c *CachingClient) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	h := make(http.Header)
+	for k, v := range e.header {
+		h[k] = v
+	}
+	return &http.Response{
+		Status:        http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+var apiVersionRe = regexp.MustCompile(`^v[0-9]+$`)
+
+//APIResource returns the name of the API resource the url points to. For
+//instance /v2/device(12) is the device resource and /user/v1/whoami is the user
+//resource.
+func APIResource(u *url.URL) string {
+	p := strings.Split(strings.Trim(u.Path, "/"), "/")
+	r := p[0]
+	if apiVersionRe.MatchString(r) && len(p) > 1 {
+		r = p[1]
+	}
+	if i := strings.IndexRune(r, '('); i != -1 {
+		r = r[:i]
+	}
+	return r
+}
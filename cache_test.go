@@ -0,0 +1,255 @@
+package resingo
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingClient struct {
+	requests int
+
+	// body of GET responses, defaults to an empty result.
+	body string
+
+	// called once while the next request is sent.
+	during func()
+}
+
+func (c *countingClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests++
+	if f := c.during; f != nil {
+		c.during = nil
+		f()
+	}
+	body := "OK"
+	if req.Method == "GET" {
+		body = `{"d":[]}`
+		if c.body != "" {
+			body = c.body
+		}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func (c *countingClient) Post(url string, bodyTyp string, body io.Reader) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestCachingClient(t *testing.T) {
+	client := &countingClient{}
+	now := time.Now()
+	cache := NewCachingClient(client, &CacheOptions{
+		MaxEntries: 2,
+		TTL: map[string]time.Duration{
+			"environment_variable": -1,
+		},
+	})
+	cache.now = func() time.Time { return now }
+	ctx := &Context{
+		Client: cache,
+		Config: &Config{ResinEndpoint: apiEndpoint, ResinVersion: VersionTwo},
+	}
+	t.Run("Hit", func(ts *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, err := DevGetAll(ctx)
+			if err != nil {
+				ts.Fatal(err)
+			}
+		}
+		if client.requests != 1 {
+			ts.Errorf("expected 1 request got %d", client.requests)
+		}
+		s := cache.Stats()
+		if s.Hits != 2 || s.Misses != 1 {
+			ts.Errorf("expected 2 hits and 1 miss got %d and %d", s.Hits, s.Misses)
+		}
+	})
+	t.Run("Expire", func(ts *testing.T) {
+		now = now.Add(DefaultCacheTTL)
+		_, err := DevGetAll(ctx)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if client.requests != 2 {
+			ts.Errorf("expected 2 requests got %d", client.requests)
+		}
+	})
+	t.Run("Invalidate", func(ts *testing.T) {
+		err := DevNote(ctx, 1, "hello")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		_, err = DevGetAll(ctx)
+		if err != nil {
+			ts.Fatal(err)
+		}
+
+		// the note and the refetch
+		if client.requests != 4 {
+			ts.Errorf("expected 4 requests got %d", client.requests)
+		}
+		if cache.Stats().Invalidations != 1 {
+			ts.Errorf("expected 1 invalidation got %d", cache.Stats().Invalidations)
+		}
+	})
+	t.Run("Disabled", func(ts *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := EnvAppGetAll(ctx, 1)
+			if err != nil {
+				ts.Fatal(err)
+			}
+		}
+		if client.requests != 6 {
+			ts.Errorf("expected 6 requests got %d", client.requests)
+		}
+	})
+	t.Run("Evict", func(ts *testing.T) {
+		for _, id := range []int64{1, 2, 3} {
+			_, _ = AppGetByID(ctx, id)
+		}
+		s := cache.Stats()
+		if s.Entries != 2 {
+			ts.Errorf("expected 2 entries got %d", s.Entries)
+		}
+		if s.Evictions != 2 {
+			ts.Errorf("expected 2 evictions got %d", s.Evictions)
+		}
+	})
+}
+
+func TestCachingClientBounds(t *testing.T) {
+	client := &countingClient{}
+	cache := NewCachingClient(client, &CacheOptions{MaxBytes: 20})
+	get := func(uri string, h http.Header) string {
+		req, err := http.NewRequest("GET", uri, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h != nil {
+			req.Header = h
+		}
+		resp, err := cache.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return string(b)
+	}
+	sample := []struct {
+		name   string
+		uri    string
+		header http.Header
+		body   string
+		cached bool
+	}{
+		{"API", apiEndpoint + "/v2/device", nil, `{"d":[]}`, true},
+		{"OtherHost", "https://img.resin.io/api/v1/image/raspberrypi3/", nil, "image", false},
+		{"HostPrefix", apiEndpoint + ".example.com/v2/device", nil, `{"d":[]}`, false},
+		{"Range", apiEndpoint + "/v2/application", http.Header{"Range": {"bytes=4-"}}, "tial", false},
+		{"TooLarge", apiEndpoint + "/v2/release", nil, strings.Repeat("x", 21), false},
+	}
+	for _, v := range sample {
+		client.requests = 0
+		client.body = v.body
+		for i := 0; i < 2; i++ {
+			if got := get(v.uri, v.header); got != v.body {
+				t.Errorf("%s: expected %q got %q", v.name, v.body, got)
+			}
+		}
+		if cached := client.requests == 1; cached != v.cached {
+			t.Errorf("%s: expected cached %v got %d requests", v.name, v.cached, client.requests)
+		}
+	}
+	if s := cache.Stats(); s.Entries != 1 || s.Bytes != 8 {
+		t.Errorf("expected 1 entry of 8 bytes got %+v", s)
+	}
+
+	// the least recently used responses are evicted to stay within MaxBytes.
+	client.body = strings.Repeat("y", 15)
+	get(apiEndpoint+"/v2/user", nil)
+	if s := cache.Stats(); s.Entries != 1 || s.Bytes != 15 || s.Evictions != 1 {
+		t.Errorf("expected the device to be evicted got %+v", s)
+	}
+}
+
+func TestCachingClientInvalidate(t *testing.T) {
+	client := &countingClient{}
+	cache := NewCachingClient(client, nil)
+	ctx := &Context{
+		Client: cache,
+		Config: &Config{ResinEndpoint: apiEndpoint, ResinVersion: VersionTwo},
+	}
+	get := func(uri string) {
+		req, _ := http.NewRequest("GET", apiEndpoint+uri, nil)
+		resp, err := cache.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	get("/v2/device")
+	get("/v2/device_tag")
+	get("/v2/device_environment_variable")
+	get("/v2/application?$expand=device")
+	get("/v2/release?$filter=belongs_to__application/device/any(d:d/id%20eq%201)")
+	get("/v2/application(1)")
+	if err := DevNote(ctx, 1, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	s := cache.Stats()
+	if s.Invalidations != 3 || s.Entries != 3 {
+		t.Errorf("expected 3 invalidations and 3 entries got %+v", s)
+	}
+
+	// a response received after an invalidation may predate the mutation.
+	client.during = func() { cache.Invalidate("release") }
+	get("/v2/application(2)")
+	if cache.Stats().Entries != 3 {
+		t.Errorf("expected the response not to be cached got %+v", cache.Stats())
+	}
+
+	// the mutation is invalidated again once its response is received.
+	client.during = func() { get("/v2/device") }
+	if err := DevNote(ctx, 1, "again"); err != nil {
+		t.Fatal(err)
+	}
+	client.requests = 0
+	get("/v2/device")
+	if client.requests != 1 {
+		t.Error("expected the device to be fetched again")
+	}
+}
+
+func TestAPIResource(t *testing.T) {
+	sample := []struct {
+		uri, expect string
+	}{
+		{"https://api.resin.io/v2/device", "device"},
+		{"https://api.resin.io/v2/device(12)", "device"},
+		{"https://api.resin.io/v2/environment_variable(3)", "environment_variable"},
+		{"https://api.resin.io/user/v1/whoami", "user"},
+		{"https://api.resin.io/application/12/generate-api-key", "application"},
+		{"https://api.resin.io/config", "config"},
+	}
+	for _, v := range sample {
+		u, err := url.Parse(v.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := APIResource(u)
+		if r != v.expect {
+			t.Errorf("expected %s got %s", v.expect, r)
+		}
+	}
+}
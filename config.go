@@ -1,6 +1,17 @@
 package resingo
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//DefaultConfigTTL is the time a cached resin configuration is considered fresh
+//when the ConfigCache has no TTL set.
+const DefaultConfigTTL = time.Hour
 
 //ResinConfig resin configuration
 type ResinConfig struct {
@@ -23,71 +34,207 @@ type ResinConfig struct {
 		Site string `json:"site"`
 	} `json:"ga"`
 
-	DeviceTypes []struct {
-		Slug              string   `json:"slug"`
-		Version           int      `json:"version"`
-		Aliases           []string `json:"aliases"`
-		Name              string   `json:"name"`
-		Arch              string   `json:"arch"`
-		State             string   `json:"state"`
-		StateInstructions struct {
-			PostProvisioning []string `json:"postProvisioning"`
-		} `json:"stateInstructions"`
-		//Instructions []string `json:"instructions"`
-		SupportsBlink bool `json:"supportsBlink"`
-		Yocto         struct {
-			Machine       string `json:"machine"`
-			Image         string `json:"image"`
-			FSType        string `json:"fstype"`
-			Version       string `json:"version"`
-			DeployArtfact string `json:"deployArtfact"`
-			Compressed    bool   `json:"compressed"`
-		} `json:"yocto"`
+	DeviceTypes []DeviceTypeInfo `json:"deviceTypes"`
+}
+
+//DeviceTypeInfo describes a device type supported by resin.
+type DeviceTypeInfo struct {
+	Slug              string   `json:"slug"`
+	Version           int      `json:"version"`
+	Aliases           []string `json:"aliases"`
+	Name              string   `json:"name"`
+	Arch              string   `json:"arch"`
+	State             string   `json:"state"`
+	StateInstructions struct {
+		PostProvisioning []string `json:"postProvisioning"`
+	} `json:"stateInstructions"`
+	//Instructions []string `json:"instructions"`
+	SupportsBlink bool  `json:"supportsBlink"`
+	Yocto         Yocto `json:"yocto"`
+	Options       []struct {
+		IsGroup bool   `json:"isGroup"`
+		Name    string `json:"name"`
+		Message string `json:"message"`
+		Options []struct {
+			Name    string   `json:"name"`
+			Message string   `json:"message"`
+			Type    string   `json:"type"`
+			CHoices []string `json:"choices"`
+		} `json:"options"`
+	} `json:"options"`
+	Configuration struct {
+		Config struct {
+			Partition struct {
+				Primary int `json:"primary"`
+			} `json:"partition"`
+		} `json:"config"`
+	} `json:"configuration"`
+	Initialization struct {
 		Options []struct {
-			IsGroup bool   `json:"isGroup"`
 			Name    string `json:"name"`
 			Message string `json:"message"`
-			Options []struct {
-				Name    string   `json:"name"`
-				Message string   `json:"message"`
-				Type    string   `json:"type"`
-				CHoices []string `json:"choices"`
-			} `json:"options"`
+			Type    string `json:"type"`
 		} `json:"options"`
-		Configuration struct {
-			Config struct {
-				Partition struct {
-					Primary int `json:"primary"`
-				} `json:"partition"`
-			} `json:"config"`
-		} `json:"configuration"`
-		Initialization struct {
-			Options []struct {
-				Name    string `json:"name"`
-				Message string `json:"message"`
-				Type    string `json:"type"`
-			} `json:"options"`
-			Operations []struct {
-				Command string `json:"command"`
-			} `json:"operations"`
-		} `json:"initialization"`
-		BuildID string `json:"buildId"`
-	} `json:"deviceTypes"`
+		Operations []struct {
+			Command string `json:"command"`
+		} `json:"operations"`
+	} `json:"initialization"`
+	BuildID string `json:"buildId"`
+}
+
+//Yocto holds details about the resin OS build of a device type.
+type Yocto struct {
+	Machine       string `json:"machine"`
+	Image         string `json:"image"`
+	FSType        string `json:"fstype"`
+	Version       string `json:"version"`
+	DeployArtfact string `json:"deployArtfact"`
+	Compressed    bool   `json:"compressed"`
 }
 
 //ConfigGetAll return resin congiguration
+//
+// This always downloads the configuration, which is a big payload. Use
+// ConfigGet to take advantage of the configuration cache.
 func ConfigGetAll(ctx *Context) (*ResinConfig, error) {
+	cfg, _, err := configFetch(ctx, "")
+	return cfg, err
+}
+
+// fetches the resin configuration. When etag is not empty it is sent as the
+// If-None-Match header, and a nil configuration is returned if the server
+// reports that the configuration was not modified.
+func configFetch(ctx *Context, etag string) (*ResinConfig, string, error) {
 	h := authHeader(ctx.Config.AuthToken)
+	h.Set("Content-Type", "application/json")
+	if etag != "" {
+		h.Set("If-None-Match", etag)
+	}
 	//uri := ctx.Config.APIEndpoint("config")
 	uri := apiEndpoint + "/config"
-	b, err := doJSON(ctx, "GET", uri, h, nil, nil)
+	req, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	req.Header = h
+	resp, err := send(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+	if !checkStatus(resp.StatusCode) {
+		return nil, "", fmt.Errorf("resingo: [%d ] %s : %s", resp.StatusCode, req.URL.RequestURI(), string(b))
 	}
 	cfg := &ResinConfig{}
 	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, resp.Header.Get("ETag"), nil
+}
+
+//ConfigCache caches the resin configuration.
+//
+// A cached configuration is used without asking the API until it is older than
+// TTL, after that it is revalidated using the ETag returned by the API, so the
+// configuration is only downloaded again when it has changed.
+//
+// The returned configuration is shared by all users of the cache and must not
+// be modified.
+type ConfigCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	cfg     *ResinConfig
+	etag    string
+	fetched time.Time
+	now     func() time.Time
+}
+
+//NewConfigCache returns a new ConfigCache which keeps the configuration fresh
+//for ttl.
+func NewConfigCache(ttl time.Duration) *ConfigCache {
+	return &ConfigCache{TTL: ttl}
+}
+
+//Get returns the cached configuration, the configuration is fetched or
+//revalidated when the cache is empty or expired.
+func (c *ConfigCache) Get(ctx *Context) (*ResinConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg != nil && c.clock().Sub(c.fetched) < c.ttl() {
+		return c.cfg, nil
+	}
+	return c.fetch(ctx, c.etag)
+}
+
+//Refresh revalidates the cached configuration with the API regardless of its
+//age.
+func (c *ConfigCache) Refresh(ctx *Context) (*ResinConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fetch(ctx, c.etag)
+}
+
+//Reset removes the cached configuration.
+func (c *ConfigCache) Reset() {
+	c.mu.Lock()
+	c.cfg = nil
+	c.etag = ""
+	c.mu.Unlock()
+}
+
+func (c *ConfigCache) fetch(ctx *Context, etag string) (*ResinConfig, error) {
+	if c.cfg == nil {
+		etag = ""
+	}
+	cfg, tag, err := configFetch(ctx, etag)
 	if err != nil {
 		return nil, err
 	}
-	return cfg, nil
+	if cfg != nil {
+		c.cfg = cfg
+	}
+	c.etag = tag
+	c.fetched = c.clock()
+	return c.cfg, nil
+}
+
+func (c *ConfigCache) ttl() time.Duration {
+	if c.TTL == 0 {
+		return DefaultConfigTTL
+	}
+	return c.TTL
+}
+
+func (c *ConfigCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+var configCacheMu sync.Mutex
+
+//ConfigGet returns the resin configuration from the configuration cache of ctx.
+//A cache with DefaultConfigTTL is created if ctx doesn't have one, set
+//Context.ConfigCache to share a cache between contexts or to use a different
+//TTL.
+func ConfigGet(ctx *Context) (*ResinConfig, error) {
+	configCacheMu.Lock()
+	if ctx.ConfigCache == nil {
+		ctx.ConfigCache = NewConfigCache(DefaultConfigTTL)
+	}
+	c := ctx.ConfigCache
+	configCacheMu.Unlock()
+	return c.Get(ctx)
 }
@@ -1,8 +1,13 @@
 package resingo
 
 import (
+	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestResinConfig(t *testing.T) {
@@ -25,3 +30,76 @@ func TestResinConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type configClient struct {
+	etag     string
+	fetches  int
+	notModif int
+}
+
+func (c *configClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("If-None-Match") == c.etag {
+		c.notModif++
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+	c.fetches++
+	h := make(http.Header)
+	h.Set("ETag", c.etag)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     h,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"deviceUrlsBase":"resindevice.io"}`)),
+	}, nil
+}
+
+func (c *configClient) Post(url string, bodyTyp string, body io.Reader) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestConfigCache(t *testing.T) {
+	client := &configClient{etag: `"v1"`}
+	ctx := &Context{
+		Client: client,
+		Config: &Config{},
+	}
+	now := time.Now()
+	c := NewConfigCache(time.Minute)
+	c.now = func() time.Time { return now }
+	ctx.ConfigCache = c
+	for i := 0; i < 3; i++ {
+		cfg, err := ConfigGet(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DeviceURLBase != "resindevice.io" {
+			t.Errorf("expected resindevice.io got %s", cfg.DeviceURLBase)
+		}
+	}
+	if client.fetches != 1 || client.notModif != 0 {
+		t.Errorf("expected one fetch got %d fetches and %d revalidations", client.fetches, client.notModif)
+	}
+
+	// expired configuration is revalidated with the etag
+	now = now.Add(2 * time.Minute)
+	_, err := ConfigGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.fetches != 1 || client.notModif != 1 {
+		t.Errorf("expected one revalidation got %d fetches and %d revalidations", client.fetches, client.notModif)
+	}
+
+	// a changed configuration is downloaded again on refresh
+	client.etag = `"v2"`
+	_, err = c.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.fetches != 2 {
+		t.Errorf("expected 2 fetches got %d", client.fetches)
+	}
+}
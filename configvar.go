@@ -0,0 +1,239 @@
+package resingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+//ConfigVarPrefix is the prefix of all config variable names.
+const ConfigVarPrefix = "RESIN_"
+
+//ErrNotConfigVar is returned when creating a config variable with a name that
+//doesn't start with ConfigVarPrefix.
+var ErrNotConfigVar = errors.New("resingo: config variable names must start with " + ConfigVarPrefix)
+
+//ErrReservedEnvVar is returned when creating an environment variable with a
+//name that starts with ConfigVarPrefix, those are config variables.
+var ErrReservedEnvVar = errors.New("resingo: environment variable names starting with " + ConfigVarPrefix + " are reserved for config variables")
+
+//DevConfigVar is a device config variable. Config variables like
+//RESIN_HOST_CONFIG_* and RESIN_SUPERVISOR_* configure resin OS and the
+//supervisor rather than the application.
+type DevConfigVar struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Device struct {
+		ID       int64 `json:"__id"`
+		Deferred struct {
+			URI string `json:"uri"`
+		} `json:"__deferred"`
+	} `json:"device"`
+	Metadata struct {
+		URI  string `json:"uri"`
+		Type string `json:"type"`
+	} `json:"__metadata"`
+}
+
+//AppConfigVar is an application config variable, it applies to all devices
+//of the application unless the device overrides it.
+type AppConfigVar struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Application struct {
+		ID       int64 `json:"__id"`
+		Deferred struct {
+			URI string `json:"uri"`
+		} `json:"__deferred"`
+	} `json:"application"`
+	Metadata struct {
+		URI  string `json:"uri"`
+		Type string `json:"type"`
+	} `json:"__metadata"`
+}
+
+//IsConfigVar returns true if name is the name of a config variable.
+func IsConfigVar(name string) bool {
+	return strings.HasPrefix(name, ConfigVarPrefix)
+}
+
+//ValidateConfigVarName returns ErrNotConfigVar if name can't be used for a
+//config variable.
+func ValidateConfigVarName(name string) error {
+	if !IsConfigVar(name) {
+		return ErrNotConfigVar
+	}
+	return nil
+}
+
+//ValidateEnvVarName returns ErrReservedEnvVar if name can't be used for an
+//environment variable.
+func ValidateEnvVarName(name string) error {
+	if IsConfigVar(name) {
+		return ErrReservedEnvVar
+	}
+	return nil
+}
+
+//ConfigVarDevCreate creates config variable for the device with the given id.
+func ConfigVarDevCreate(ctx *Context, id int64, key, value string) (*DevConfigVar, error) {
+	err := ValidateConfigVarName(key)
+	if err != nil {
+		return nil, err
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("device_config_variable")
+	data := make(map[string]interface{})
+	data["device"] = id
+	data["name"] = key
+	data["value"] = value
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "POST", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	e := &DevConfigVar{}
+	err = json.Unmarshal(b, e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+//ConfigVarDevGetAll returns all config variables of the device with the given
+//id.
+func ConfigVarDevGetAll(ctx *Context, id int64) ([]*DevConfigVar, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("device_config_variable")
+	param := make(url.Values)
+	param.Set("filter", "device")
+	param.Set("eq", fmt.Sprint(id))
+	b, err := doJSON(ctx, "GET", uri, h, param, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := struct {
+		D []*DevConfigVar `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.D, nil
+}
+
+//ConfigVarDevUpdate updates device config variable. The id is for the config
+//variable.
+func ConfigVarDevUpdate(ctx *Context, id int64, value string) error {
+	return resourceSetValue(ctx, fmt.Sprintf("device_config_variable(%d)", id), value)
+}
+
+//ConfigVarDevDelete deletes device config variable
+func ConfigVarDevDelete(ctx *Context, id int64) error {
+	return resourceDelete(ctx, fmt.Sprintf("device_config_variable(%d)", id))
+}
+
+//ConfigVarAppCreate creates config variable for the application with the
+//given id.
+func ConfigVarAppCreate(ctx *Context, id int64, key, value string) (*AppConfigVar, error) {
+	err := ValidateConfigVarName(key)
+	if err != nil {
+		return nil, err
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("application_config_variable")
+	data := make(map[string]interface{})
+	data["application"] = id
+	data["name"] = key
+	data["value"] = value
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "POST", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	e := &AppConfigVar{}
+	err = json.Unmarshal(b, e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+//ConfigVarAppGetAll returns all config variables of the application with the
+//given id.
+func ConfigVarAppGetAll(ctx *Context, id int64) ([]*AppConfigVar, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("application_config_variable")
+	param := make(url.Values)
+	param.Set("filter", "application")
+	param.Set("eq", fmt.Sprint(id))
+	b, err := doJSON(ctx, "GET", uri, h, param, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := struct {
+		D []*AppConfigVar `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.D, nil
+}
+
+//ConfigVarAppUpdate updates application config variable. The id is for the
+//config variable.
+func ConfigVarAppUpdate(ctx *Context, id int64, value string) error {
+	return resourceSetValue(ctx, fmt.Sprintf("application_config_variable(%d)", id), value)
+}
+
+//ConfigVarAppDelete deletes application config variable
+func ConfigVarAppDelete(ctx *Context, id int64) error {
+	return resourceDelete(ctx, fmt.Sprintf("application_config_variable(%d)", id))
+}
+
+// patches the value of the given resource, resource is the endpoint of a
+// single item like device_config_variable(12).
+func resourceSetValue(ctx *Context, resource, value string) error {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(resource)
+	data := make(map[string]interface{})
+	data["value"] = value
+	body, err := marhsalReader(data)
+	if err != nil {
+		return err
+	}
+	b, err := doJSON(ctx, "PATCH", uri, h, nil, body)
+	if err != nil {
+		return err
+	}
+	if string(b) != "OK" {
+		return errors.New("bad response")
+	}
+	return nil
+}
+
+// deletes the given resource, resource is the endpoint of a single item like
+// device_config_variable(12).
+func resourceDelete(ctx *Context, resource string) error {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(resource)
+	b, err := doJSON(ctx, "DELETE", uri, h, nil, nil)
+	if err != nil {
+		return err
+	}
+	if string(b) != "OK" {
+		return errors.New("bad response")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package resingo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConfigVarName(t *testing.T) {
+	sample := []struct {
+		name     string
+		isConfig bool
+	}{
+		{"RESIN_HOST_CONFIG_gpu_mem", true},
+		{"RESIN_SUPERVISOR_POLL_INTERVAL", true},
+		{"MONIKER", false},
+		{"resin_lowercase", false},
+	}
+	for _, v := range sample {
+		if IsConfigVar(v.name) != v.isConfig {
+			t.Errorf("%s: expected %v got %v", v.name, v.isConfig, !v.isConfig)
+		}
+		cerr, eerr := ValidateConfigVarName(v.name), ValidateEnvVarName(v.name)
+		if v.isConfig {
+			if cerr != nil || eerr != ErrReservedEnvVar {
+				t.Errorf("%s: expected a valid config variable name got %v %v", v.name, cerr, eerr)
+			}
+			continue
+		}
+		if cerr != ErrNotConfigVar || eerr != nil {
+			t.Errorf("%s: expected a valid environment variable name got %v %v", v.name, cerr, eerr)
+		}
+	}
+}
+
+func TestConfigVar(t *testing.T) {
+	config := &Config{
+		Username:      ENV.Username,
+		Password:      ENV.Password,
+		ResinEndpoint: apiEndpoint,
+	}
+	client := &http.Client{}
+	ctx := &Context{
+		Client: client,
+		Config: config,
+	}
+	err := Login(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appName := "configvar_test"
+	app, err := AppCreate(ctx, appName, RaspberryPi3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = AppDelete(ctx, app.ID)
+	}()
+	uuid, err := GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := DevRegister(ctx, appName, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, value := "RESIN_HOST_CONFIG_gpu_mem", "64"
+	t.Run("App", func(ts *testing.T) {
+		_, err := ConfigVarAppCreate(ctx, app.ID, "MONIKER", value)
+		if err != ErrNotConfigVar {
+			ts.Errorf("expected %v got %v", ErrNotConfigVar, err)
+		}
+		_, err = ConfigVarAppCreate(ctx, app.ID, name, value)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		vars, err := ConfigVarAppGetAll(ctx, app.ID)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		for _, v := range vars {
+			if v.Name != name {
+				continue
+			}
+			err = ConfigVarAppUpdate(ctx, v.ID, "128")
+			if err != nil {
+				ts.Error(err)
+			}
+			err = ConfigVarAppDelete(ctx, v.ID)
+			if err != nil {
+				ts.Error(err)
+			}
+		}
+	})
+	t.Run("Device", func(ts *testing.T) {
+		_, err := EnvDevCreate(ctx, dev.ID, name, value)
+		if err != ErrReservedEnvVar {
+			ts.Errorf("expected %v got %v", ErrReservedEnvVar, err)
+		}
+		_, err = ConfigVarDevCreate(ctx, dev.ID, name, value)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		vars, err := ConfigVarDevGetAll(ctx, dev.ID)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if len(vars) == 0 {
+			ts.Fatal("expected at least one config variable")
+		}
+		for _, v := range vars {
+			err = ConfigVarDevUpdate(ctx, v.ID, "128")
+			if err != nil {
+				ts.Error(err)
+			}
+			err = ConfigVarDevDelete(ctx, v.ID)
+			if err != nil {
+				ts.Error(err)
+			}
+		}
+	})
+}
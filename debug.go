@@ -0,0 +1,220 @@
+package resingo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DebugEnv is the environment variable enabling the debug dump when it is set
+//to a true value like 1 or true, it has the same effect as Config.Debug.
+const DebugEnv = "RESINGO_DEBUG"
+
+//DefaultDebugMaxBody is the number of bytes of a body dumped when
+//Config.DebugMaxBody is not set.
+const DefaultDebugMaxBody = 4096
+
+// serializes the dumps, so concurrent requests don't interleave.
+var debugMu sync.Mutex
+
+func (c *Config) debug() bool {
+	if c.Debug {
+		return true
+	}
+	ok, _ := strconv.ParseBool(os.Getenv(DebugEnv))
+	return ok
+}
+
+func (c *Config) debugOutput() io.Writer {
+	if c.DebugOutput != nil {
+		return c.DebugOutput
+	}
+	return os.Stderr
+}
+
+func (c *Config) debugMaxBody() int {
+	if c.DebugMaxBody == 0 {
+		return DefaultDebugMaxBody
+	}
+	return c.DebugMaxBody
+}
+
+// dumps a request and its response. The request is written as a curl command
+// and the response as shell comments, so the dump can be pasted in a shell to
+// reproduce the request. Credentials are redacted.
+type debugDump struct {
+	out    io.Writer
+	max    int
+	method string
+	u      *url.URL
+	header http.Header
+	body   []byte
+	start  time.Time
+}
+
+// reads the body of req, which is replaced so it can still be sent. The url
+// and header are copied, transports may change them.
+func newDebugDump(c *Config, req *http.Request) (*debugDump, error) {
+	u := *req.URL
+	d := &debugDump{
+		out:    c.debugOutput(),
+		max:    c.debugMaxBody(),
+		method: req.Method,
+		u:      &u,
+		header: req.Header.Clone(),
+		start:  time.Now(),
+	}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		d.body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	return d, nil
+}
+
+// returns b truncated to the maximum body size and whether it was truncated.
+func (d *debugDump) truncate(b []byte) ([]byte, bool) {
+	if d.max < 0 || len(b) <= d.max {
+		return b, false
+	}
+	return b[:d.max], true
+}
+
+func (d *debugDump) writeRequest(w io.Writer) {
+	fmt.Fprintf(w, "# resingo: %s %s %s\n", d.start.Format(time.RFC3339), d.method, redactURL(d.u))
+	fmt.Fprintf(w, "curl -X %s %s", d.method, shellQuote(redactURL(d.u)))
+	h := redactHeader(d.header)
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, " \\\n  -H %s", shellQuote(k+": "+v))
+		}
+	}
+	if len(d.body) == 0 {
+		io.WriteString(w, "\n")
+		return
+	}
+	b, truncated := d.truncate(redactBody(d.u, d.header.Get("Content-Type"), d.body, false))
+	fmt.Fprintf(w, " \\\n  --data-binary %s\n", shellQuote(string(b)))
+	if truncated {
+		fmt.Fprintf(w, "# request body truncated to %d of %d bytes\n", len(b), len(d.body))
+	}
+}
+
+func (d *debugDump) writeError(err error) {
+	var buf bytes.Buffer
+	d.writeRequest(&buf)
+	fmt.Fprintf(&buf, "# error (%s): %v\n\n", time.Since(d.start), redactError(err))
+	d.flush(&buf)
+}
+
+func (d *debugDump) writeResponse(resp *http.Response, b []byte, size int64, err error) {
+	var buf bytes.Buffer
+	d.writeRequest(&buf)
+	fmt.Fprintf(&buf, "# response (%s): %s\n", time.Since(d.start), resp.Status)
+	h := redactHeader(resp.Header)
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(&buf, "# %s: %s\n", k, v)
+		}
+	}
+	captured := len(b)
+	b, truncated := d.truncate(redactBody(d.u, resp.Header.Get("Content-Type"), b, true))
+	if len(b) > 0 {
+		buf.WriteString("#\n")
+		for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+			fmt.Fprintf(&buf, "# %s\n", line)
+		}
+	}
+	if truncated || int64(captured) < size {
+		fmt.Fprintf(&buf, "# response body truncated to %d of %d bytes\n", len(b), size)
+	}
+	if err != nil {
+		fmt.Fprintf(&buf, "# error reading body: %v\n", err)
+	}
+	buf.WriteString("\n")
+	d.flush(&buf)
+}
+
+func (d *debugDump) flush(buf *bytes.Buffer) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	_, _ = buf.WriteTo(d.out)
+}
+
+// captures the response body while it is read, and dumps the response when
+// it is closed. Text, form and JSON bodies are captured whole, so they can be
+// redacted before they are truncated. Only the dumped part of other bodies is
+// kept, so large downloads are not held in memory.
+type debugBody struct {
+	io.ReadCloser
+	d     *debugDump
+	resp  *http.Response
+	whole bool
+	buf   bytes.Buffer
+	size  int64
+	err   error
+	once  sync.Once
+}
+
+func newDebugBody(d *debugDump, resp *http.Response) *debugBody {
+	typ, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	whole := d.max < 0 || isSecretResponse(d.u) || typ == "text/plain" ||
+		typ == "application/json" || strings.HasSuffix(typ, "+json") ||
+		typ == "application/x-www-form-urlencoded"
+	return &debugBody{ReadCloser: resp.Body, d: d, resp: resp, whole: whole}
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	keep := n
+	if !b.whole {
+		if room := b.d.max - b.buf.Len(); keep > room {
+			keep = room
+		}
+	}
+	if keep > 0 {
+		b.buf.Write(p[:keep])
+	}
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *debugBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.d.writeResponse(b.resp, b.buf.Bytes(), b.size, b.err)
+	})
+	return err
+}
+
+// quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
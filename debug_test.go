@@ -0,0 +1,180 @@
+package resingo
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestDebug(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login_":
+			fmt.Fprint(w, "secret-session-token")
+		case "/v1/device":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"d":[{"uuid":"aaa","apikey":"secret-device-key"}]}`)
+		case "/blob":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, strings.Repeat("x", 100))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	var buf bytes.Buffer
+	ctx := &Context{
+		Client: &http.Client{Transport: &rewriteTransport{target: target}},
+		Config: &Config{
+			ResinEndpoint: apiEndpoint,
+			Username:      "me",
+			Password:      "secret-password",
+			AuthToken:     "secret-auth-token",
+			Debug:         true,
+			DebugOutput:   &buf,
+			DebugMaxBody:  40,
+		},
+	}
+	tok, err := Authenticate(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != "secret-session-token" {
+		t.Errorf("expected the body to be read by the caller got %s", tok)
+	}
+	devs, err := DevGetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 {
+		t.Errorf("expected a device got %v", devs)
+	}
+	_, err = do(ctx, "GET", apiEndpoint+"/blob", make(http.Header), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	sample := []string{
+		"curl -X POST 'https://api.resin.io/login_' \\\n  -H 'Content-Type: application/x-www-form-urlencoded' \\\n  --data-binary 'password=%5BREDACTED%5D&username=me'\n",
+		"# response (",
+		"): 200 OK\n",
+		"#\n# [REDACTED]\n",
+		"curl -X GET 'https://api.resin.io/v1/device' \\\n  -H 'Authorization: Bearer [REDACTED]' \\\n  -H 'Content-Type: application/json'\n",
+		"# Content-Type: application/json\n",
+		"#\n# {\"d\":[{\"apikey\":\"[REDACTED]\",\"uuid\":\"aaa\n# response body truncated to 40 of 51 bytes\n",
+		"# " + strings.Repeat("x", 40) + "\n# response body truncated to 40 of 100 bytes\n",
+	}
+	for _, v := range sample {
+		if !strings.Contains(out, v) {
+			t.Errorf("expected %q in\n%s", v, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("expected the credentials to be redacted got\n%s", out)
+	}
+	if strings.Contains(out, target.Host) {
+		t.Errorf("expected the url before the transport rewrote it got\n%s", out)
+	}
+
+	buf.Reset()
+	ctx.Config.DebugMaxBody = -1
+	if _, err = do(ctx, "GET", apiEndpoint+"/blob", make(http.Header), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), strings.Repeat("x", 100)) || strings.Contains(buf.String(), "truncated") {
+		t.Errorf("expected the whole body got\n%s", buf.String())
+	}
+
+	buf.Reset()
+	ctx.Config.Debug = false
+	if _, err = DevGetAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no dump got\n%s", buf.String())
+	}
+	t.Setenv(DebugEnv, "true")
+	ts.Close()
+	ctx.Config.ResinEndpoint = ts.URL
+	if _, err = DevGetAll(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(buf.String(), "# error (") {
+		t.Errorf("expected the error to be dumped got\n%s", buf.String())
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	sample := []struct {
+		src, expect string
+	}{
+		{"", "''"},
+		{"a b", "'a b'"},
+		{`{"name":"it's"}`, `'{"name":"it'\''s"}'`},
+	}
+	for _, v := range sample {
+		if got := shellQuote(v.src); got != v.expect {
+			t.Errorf("expected %s got %s", v.expect, got)
+		}
+	}
+}
+
+func TestDebugRegister(t *testing.T) {
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &TokenClain{
+		Username: "me",
+		UserID:   1,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}).SignedString([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user/register":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, tok)
+		case "/user/v1/whoami":
+			fmt.Fprint(w, `{"id":1,"username":"me"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	var buf bytes.Buffer
+	ctx := &Context{
+		Client: &http.Client{Transport: &rewriteTransport{target: target}},
+		Config: &Config{
+			ResinEndpoint: apiEndpoint,
+			Debug:         true,
+			DebugOutput:   &buf,
+			DebugMaxBody:  10,
+		},
+	}
+	u, err := UserRegister(ctx, "me", "me@example.com", "secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != "me" {
+		t.Errorf("expected the registered user got %+v", u)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "curl -X POST 'https://api.resin.io/user/register'") {
+		t.Errorf("expected the register request in\n%s", out)
+	}
+	for _, v := range []string{tok, tok[:10], "secret-password"} {
+		if strings.Contains(out, v) {
+			t.Errorf("expected %q to be redacted got\n%s", v, out)
+		}
+	}
+}
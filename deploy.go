@@ -0,0 +1,153 @@
+package resingo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+//ErrNoRepository is returned when deploying to an application without a git
+//repository.
+var ErrNoRepository = errors.New("resingo: application has no git repository")
+
+// resin only builds code pushed to the master branch of the application
+// repository.
+const deployRef = plumbing.ReferenceName("refs/heads/master")
+
+//DeployOptions configures Deploy.
+type DeployOptions struct {
+	// Path is the path to the local git repository or to any directory inside
+	// its worktree, the current directory is used when empty.
+	Path string
+
+	// Ref is the local reference to deploy, HEAD is used when empty.
+	Ref string
+
+	// Remote overrides the git remote of the application. The ssh user is git
+	// when the remote has none.
+	Remote string
+
+	// PrivateKey is the PEM encoded ssh private key used to authenticate with
+	// the git server. The matching public key should be registered with
+	// KeyCreate. Password decrypts the key if it is encrypted.
+	PrivateKey []byte
+	Password   string
+
+	// HostKeyCallback verifies the git server, the host key is checked
+	// against the known_hosts files when nil.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Output receives the output of the resin builder as it is streamed by the
+	// git server.
+	Output io.Writer
+
+	// Force overwrites the remote master branch, it is needed when deploying
+	// a commit which is not a descendant of the currently deployed commit.
+	Force bool
+}
+
+//Deploy pushes the local git repository to the git remote of the application
+//with the given appID, which triggers a build of a new release on resin. The
+//hash of the deployed commit is returned. The repository in the current
+//directory is deployed when opts is nil.
+//
+//	commit, err := resingo.Deploy(ctx, app.ID, &resingo.DeployOptions{
+//		Path:       ".",
+//		PrivateKey: key,
+//		Output:     os.Stdout,
+//	})
+func Deploy(ctx *Context, appID int64, opts *DeployOptions) (string, error) {
+	if opts == nil {
+		opts = &DeployOptions{}
+	}
+	remote := opts.Remote
+	if remote == "" {
+		app, err := AppGetByID(ctx, appID)
+		if err != nil {
+			return "", err
+		}
+		if app.Repository == "" {
+			return "", ErrNoRepository
+		}
+		remote = app.Repository
+	}
+	return deployPush(remote, opts)
+}
+
+func deployPush(remote string, opts *DeployOptions) (string, error) {
+	path := opts.Path
+	if path == "" {
+		path = "."
+	}
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	ref := opts.Ref
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return "", err
+	}
+	auth, err := deployAuth(remote, opts)
+	if err != nil {
+		return "", err
+	}
+	spec := config.RefSpec(fmt.Sprintf("%s:%s", hash, deployRef))
+	if opts.Force {
+		spec = "+" + spec
+	}
+	r := git.NewRemote(repo.Storer, &config.RemoteConfig{
+		Name: "resin",
+		URLs: []string{remote},
+	})
+	err = r.Push(&git.PushOptions{
+		RemoteName: "resin",
+		RefSpecs:   []config.RefSpec{spec},
+		Auth:       auth,
+		Progress:   opts.Output,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// the ssh user of remotes without one, like git servers usually expect.
+const deployUser = "git"
+
+// returns the ssh authentication method for remote, local remotes don't need
+// any authentication.
+func deployAuth(remote string, opts *DeployOptions) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return nil, err
+	}
+	if ep.Protocol != "ssh" || len(opts.PrivateKey) == 0 {
+		return nil, nil
+	}
+	user := ep.User
+	if user == "" {
+		user = deployUser
+	}
+	auth, err := gitssh.NewPublicKeys(user, opts.PrivateKey, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+	if opts.HostKeyCallback != nil {
+		auth.HostKeyCallback = opts.HostKeyCallback
+	}
+	return auth, nil
+}
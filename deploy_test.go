@@ -0,0 +1,317 @@
+package resingo
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestDeploy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resingo-deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	src := filepath.Join(dir, "src")
+	commit := testDeployRepo(t, src)
+
+	t.Run("Local", func(ts *testing.T) {
+		bare := filepath.Join(dir, "local.git")
+		_, err := git.PlainInit(bare, true)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		h, err := deployPush(bare, &DeployOptions{Path: filepath.Join(src, "app")})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if h != commit {
+			ts.Errorf("expected %s got %s", commit, h)
+		}
+		testDeployRemoteHead(ts, bare, commit)
+
+		// pushing the same commit again is not an error
+		_, err = deployPush(bare, &DeployOptions{Path: src})
+		if err != nil {
+			ts.Error(err)
+		}
+	})
+	t.Run("NilOptions", func(ts *testing.T) {
+		bare := filepath.Join(dir, "nil.git")
+		_, err := git.PlainInit(bare, true)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/application(7)" {
+				http.NotFound(w, r)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"d": []*Application{{ID: 7, Repository: bare}},
+			})
+		}))
+		defer api.Close()
+		ctx := &Context{
+			Client: &http.Client{},
+			Config: &Config{ResinEndpoint: api.URL},
+		}
+		ts.Chdir(filepath.Join(src, "app"))
+		h, err := Deploy(ctx, 7, nil)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if h != commit {
+			ts.Errorf("expected %s got %s", commit, h)
+		}
+		testDeployRemoteHead(ts, bare, commit)
+	})
+	t.Run("SSH", func(ts *testing.T) {
+		if _, err := exec.LookPath("git-receive-pack"); err != nil {
+			if _, err = exec.LookPath("git"); err != nil {
+				ts.Skip("git is not installed")
+			}
+		}
+		bare := filepath.Join(dir, "ssh.git")
+		_, err := git.PlainInit(bare, true)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		hook := filepath.Join(bare, "hooks", "pre-receive")
+		err = os.MkdirAll(filepath.Dir(hook), 0755)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		err = ioutil.WriteFile(hook, []byte("#!/bin/sh\necho '[Info] Building release'\n"), 0755)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		clientKey, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		block, err := ssh.MarshalPrivateKey(priv, "")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		addr, hostKey, stop := testGitSSHServer(ts, clientKey)
+		defer stop()
+
+		var out bytes.Buffer
+		remote := fmt.Sprintf("ssh://gernest@%s%s", addr, bare)
+		h, err := deployPush(remote, &DeployOptions{
+			Path:            src,
+			PrivateKey:      pem.EncodeToMemory(block),
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			Output:          &out,
+		})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if h != commit {
+			ts.Errorf("expected %s got %s", commit, h)
+		}
+		testDeployRemoteHead(ts, bare, commit)
+		if !strings.Contains(out.String(), "Building release") {
+			ts.Errorf("expected builder output got %q", out.String())
+		}
+	})
+}
+
+// creates a git repository at dir with a single commit and returns the commit
+// hash.
+func testDeployRepo(t *testing.T, dir string) string {
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Join(dir, "app"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "app", "main.go"), []byte("package main\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Add("app/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := w.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  "resingo",
+			Email: "resingo@example.com",
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.String()
+}
+
+func testDeployRemoteHead(t *testing.T, bare, commit string) {
+	repo, err := git.PlainOpen(bare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Reference(plumbing.ReferenceName("refs/heads/master"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash().String() != commit {
+		t.Errorf("expected remote master at %s got %s", commit, ref.Hash())
+	}
+}
+
+// starts a ssh server that only accepts clientKey and serves
+// git-receive-pack using the git binary.
+func testGitSSHServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey, func()) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key for %s", c.User())
+		},
+	}
+	cfg.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go testServeGitSSH(conn, cfg)
+		}
+	}()
+	return l.Addr().String(), signer.PublicKey(), func() {
+		_ = l.Close()
+	}
+}
+
+func testServeGitSSH(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer func() {
+				_ = ch.Close()
+			}()
+			for req := range chReqs {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				_ = req.Reply(true, nil)
+				var payload struct{ Command string }
+				_ = ssh.Unmarshal(req.Payload, &payload)
+				args := strings.SplitN(payload.Command, " ", 2)
+				if len(args) != 2 || args[0] != "git-receive-pack" {
+					return
+				}
+				cmd := exec.Command("git", "receive-pack", strings.Trim(args[1], "'"))
+				cmd.Stdin = ch
+				cmd.Stdout = ch
+				cmd.Stderr = ch.Stderr()
+				status := struct{ Status uint32 }{}
+				if err := cmd.Run(); err != nil {
+					status.Status = 1
+				}
+				_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(&status))
+				return
+			}
+		}()
+	}
+}
+
+func TestDeployAuth(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &DeployOptions{PrivateKey: pem.EncodeToMemory(block)}
+	sample := []struct {
+		remote, user string
+	}{
+		{"gernest@git.resin.io:gernest/app.git", "gernest"},
+		{"git.resin.io:myorg/app.git", "git"},
+		{"ssh://git.resin.io/myorg/app.git", "git"},
+		{"ssh://me@git.resin.io:2222/myorg/app.git", "me"},
+	}
+	for _, v := range sample {
+		auth, err := deployAuth(v.remote, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", v.remote, err)
+		}
+		k, ok := auth.(*gitssh.PublicKeys)
+		if !ok {
+			t.Fatalf("%s: expected public key authentication got %T", v.remote, auth)
+		}
+		if k.User != v.user {
+			t.Errorf("%s: expected user %s got %s", v.remote, v.user, k.User)
+		}
+	}
+	for _, remote := range []string{"/srv/git/app.git", "file:///srv/git/app.git"} {
+		auth, err := deployAuth(remote, opts)
+		if err != nil || auth != nil {
+			t.Errorf("%s: expected no authentication got %v %v", remote, auth, err)
+		}
+	}
+}
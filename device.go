@@ -50,15 +50,26 @@ type Device struct {
 	Location              string    `json:"location"`
 	Longitude             string    `json:"longitude"`
 	Latitude              string    `json:"latitude"`
+	CustomLongitude       string    `json:"custom_longitude"`
+	CustomLatitude        string    `json:"custom_latitude"`
 	LogsChannel           string    `json:"logs_channel"`
+	DownloadProgress      null.Int  `json:"download_progress"`
+	ProvisioningProgress  null.Int  `json:"provisioning_progress"`
+	ProvisioningState     string    `json:"provisioning_state"`
+	CPUUsage              null.Int  `json:"cpu_usage"`
+	CPUTemp               null.Int  `json:"cpu_temp"`
+	MemoryUsage           null.Int  `json:"memory_usage"`
+	MemoryTotal           null.Int  `json:"memory_total"`
+	StorageUsage          null.Int  `json:"storage_usage"`
+	StorageTotal          null.Int  `json:"storage_total"`
 }
 
 //DevGetAll returns all devices that belong to the user who authorized the
-//context ctx.
-func DevGetAll(ctx *Context) ([]*Device, error) {
+//context ctx, and match all filters.
+func DevGetAll(ctx *Context, filters ...Filter) ([]*Device, error) {
 	h := authHeader(ctx.Config.AuthToken)
 	uri := ctx.Config.APIEndpoint("device")
-	b, err := doJSON(ctx, "GET", uri, h, nil, nil)
+	b, err := doJSON(ctx, "GET", uri, h, filterParams(filters), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +118,6 @@ func DevRegister(ctx *Context, appName, uuid string) (*Device, error) {
 		return nil, err
 	}
 	b, err := doJSON(ctx, "POST", uri, h, nil, body)
-	//fmt.Println(string(b))
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +144,6 @@ func DevGetByUUID(ctx *Context, uuid string) (*Device, error) {
 	var devRes = struct {
 		D []*Device `json:"d"`
 	}{}
-	//fmt.Println(string(b))
 	err = json.Unmarshal(b, &devRes)
 	if err != nil {
 		return nil, err
@@ -156,7 +165,6 @@ func DevGetByName(ctx *Context, name string) (*Device, error) {
 	if err != nil {
 		return nil, err
 	}
-	//fmt.Println(string(b))
 	var devRes = struct {
 		D []*Device `json:"d"`
 	}{}
@@ -165,7 +173,6 @@ func DevGetByName(ctx *Context, name string) (*Device, error) {
 		return nil, err
 	}
 	if len(devRes.D) > 0 {
-		//fmt.Println(*devRes.D[0])
 		return devRes.D[0], nil
 	}
 	return nil, ErrDeviceNotFound
@@ -200,7 +207,6 @@ func DevGetAllByApp(ctx *Context, appID int64) ([]*Device, error) {
 			Device []*Device `json:"device"`
 		} `json:"d"`
 	}{}
-	//fmt.Println(string(b))
 	err = json.Unmarshal(b, &devRes)
 	if err != nil {
 		return nil, err
@@ -262,7 +268,7 @@ func DevGetApp(ctx *Context, uuid string) (*Application, error) {
 //DevEnableURL enables the device url. This allows the device to be accessed
 //anywhere using the url which uses resin vpn.
 //
-// NOTE: It is awskward to retrurn OK rather than the url which was enabled.
+// Use DevEnablePublicURL to get the url which was enabled.
 func DevEnableURL(ctx *Context, uuid string) error {
 	dev, err := DevGetByUUID(ctx, uuid)
 	if err != nil {
@@ -3,6 +3,7 @@ package resingo
 import (
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -249,6 +250,13 @@ func testDevEnableURL(ctx *Context, t *testing.T, uuid string) {
 	if !dev.WebAccessible {
 		t.Error("the device should be web accessible")
 	}
+	u, err := DevGetURL(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u, "https://"+uuid+".") {
+		t.Errorf("unexpected device url %s", u)
+	}
 }
 func testDevDisableURL(ctx *Context, t *testing.T, uuid string) {
 	err := DevDisableURL(ctx, uuid)
@@ -0,0 +1,144 @@
+package resingo
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+//ErrUnknownDeviceType is returned when a device type slug is not known by the
+//DeviceTypeRegistry.
+var ErrUnknownDeviceType = errors.New("resingo: unknown device type")
+
+//DeviceTypeRegistry resolves device types supported by resin. It is built from
+//the device types returned by the API, so new boards are supported without a
+//new release of this library.
+//
+// Lookups are case insensitive and work with both the slug and any of the
+// aliases of a device type.
+type DeviceTypeRegistry struct {
+	types []*DeviceTypeInfo
+	index map[string]*DeviceTypeInfo
+}
+
+//NewDeviceTypeRegistry returns a registry for the given device types.
+func NewDeviceTypeRegistry(types []DeviceTypeInfo) *DeviceTypeRegistry {
+	r := &DeviceTypeRegistry{index: make(map[string]*DeviceTypeInfo)}
+	for i := range types {
+		d := &types[i]
+		r.types = append(r.types, d)
+		r.index[strings.ToLower(d.Slug)] = d
+	}
+
+	// aliases are indexed after all slugs, so an alias can never shadow the
+	// slug of another device type.
+	for _, d := range r.types {
+		for _, a := range d.Aliases {
+			a = strings.ToLower(a)
+			if _, ok := r.index[a]; !ok {
+				r.index[a] = d
+			}
+		}
+	}
+	return r
+}
+
+//Lookup returns the device type with the given slug or alias.
+func (r *DeviceTypeRegistry) Lookup(slug string) (*DeviceTypeInfo, error) {
+	d, ok := r.index[strings.ToLower(slug)]
+	if !ok {
+		return nil, ErrUnknownDeviceType
+	}
+	return d, nil
+}
+
+//Resolve returns the canonical slug for the given slug or alias.
+func (r *DeviceTypeRegistry) Resolve(slug string) (DeviceType, error) {
+	d, err := r.Lookup(slug)
+	if err != nil {
+		return "", err
+	}
+	return DeviceType(d.Slug), nil
+}
+
+//Name returns the display name of the device type with the given slug or
+//alias.
+func (r *DeviceTypeRegistry) Name(slug string) (string, error) {
+	d, err := r.Lookup(slug)
+	if err != nil {
+		return "", err
+	}
+	return d.Name, nil
+}
+
+//Arch returns the cpu architecture of the device type with the given slug or
+//alias.
+func (r *DeviceTypeRegistry) Arch(slug string) (string, error) {
+	d, err := r.Lookup(slug)
+	if err != nil {
+		return "", err
+	}
+	return d.Arch, nil
+}
+
+//ByArch returns all device types with the given cpu architecture.
+func (r *DeviceTypeRegistry) ByArch(arch string) []*DeviceTypeInfo {
+	var rst []*DeviceTypeInfo
+	for _, d := range r.types {
+		if d.Arch == arch {
+			rst = append(rst, d)
+		}
+	}
+	return rst
+}
+
+//Slugs returns the sorted slugs of all device types in the registry.
+func (r *DeviceTypeRegistry) Slugs() []string {
+	var rst []string
+	for _, d := range r.types {
+		rst = append(rst, d.Slug)
+	}
+	sort.Strings(rst)
+	return rst
+}
+
+//All returns all device types in the registry.
+func (r *DeviceTypeRegistry) All() []*DeviceTypeInfo {
+	return r.types
+}
+
+//DevTypeGetAll returns all device types supported by resin.
+func DevTypeGetAll(ctx *Context) ([]DeviceTypeInfo, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := apiEndpoint + "/device-types/v1"
+	b, err := doJSON(ctx, "GET", uri, h, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var rst []DeviceTypeInfo
+	err = json.Unmarshal(b, &rst)
+	if err != nil {
+		return nil, err
+	}
+	return rst, nil
+}
+
+//DevTypeGetRegistry returns a registry of the device types supported by resin.
+//
+// The device types are taken from the resin configuration, the device types
+// endpoint is only used when the configuration doesn't have any.
+func DevTypeGetRegistry(ctx *Context) (*DeviceTypeRegistry, error) {
+	cfg, err := ConfigGet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	types := cfg.DeviceTypes
+	if len(types) == 0 {
+		types, err = DevTypeGetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewDeviceTypeRegistry(types), nil
+}
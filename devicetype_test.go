@@ -0,0 +1,77 @@
+package resingo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const sampleDeviceTypes = `[
+	{"slug":"raspberrypi3","name":"Raspberry Pi 3","arch":"armv7hf","aliases":["raspberrypi3"]},
+	{"slug":"raspberry-pi","name":"Raspberry Pi (v1 and Zero)","arch":"rpi","aliases":["raspberrypi"]},
+	{"slug":"intel-nuc","name":"Intel NUC","arch":"amd64","aliases":["nuc"]},
+	{"slug":"ts7700","name":"Technologic TS-7700","arch":"armel","aliases":[]}
+]`
+
+func TestDeviceTypeRegistry(t *testing.T) {
+	var types []DeviceTypeInfo
+	err := json.Unmarshal([]byte(sampleDeviceTypes), &types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewDeviceTypeRegistry(types)
+	t.Run("Resolve", func(ts *testing.T) {
+		sample := []struct {
+			slug   string
+			expect DeviceType
+		}{
+			{"raspberrypi3", RaspberryPi3},
+			{"raspberrypi", RaspberryPi},
+			{"NUC", IntelNuc},
+			{Ts700.String(), Ts7700},
+		}
+		for _, v := range sample {
+			slug, err := reg.Resolve(v.slug)
+			if err != nil {
+				ts.Fatal(err)
+			}
+			if slug != v.expect {
+				ts.Errorf("expected %s got %s", v.expect, slug)
+			}
+		}
+		_, err := reg.Resolve("toaster")
+		if err != ErrUnknownDeviceType {
+			ts.Errorf("expected %v got %v", ErrUnknownDeviceType, err)
+		}
+	})
+	t.Run("Name", func(ts *testing.T) {
+		name, err := reg.Name("nuc")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if name != "Intel NUC" {
+			ts.Errorf("expected Intel NUC got %s", name)
+		}
+	})
+	t.Run("Arch", func(ts *testing.T) {
+		arch, err := reg.Arch(RaspberryPi3.String())
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if arch != "armv7hf" {
+			ts.Errorf("expected armv7hf got %s", arch)
+		}
+		amd := reg.ByArch("amd64")
+		if len(amd) != 1 || amd[0].Slug != "intel-nuc" {
+			ts.Errorf("expected intel-nuc got %v", amd)
+		}
+	})
+	t.Run("Slugs", func(ts *testing.T) {
+		s := reg.Slugs()
+		if len(s) != len(types) {
+			ts.Fatalf("expected %d got %d", len(types), len(s))
+		}
+		if s[0] != "intel-nuc" {
+			ts.Errorf("expected intel-nuc got %s", s[0])
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package resingo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//ErrDeviceURLDisabled is returned when asking for the public url of a device
+//which is not web accessible.
+var ErrDeviceURLDisabled = errors.New("resingo: device url is not enabled")
+
+//DefaultProbeTimeout is the timeout of DevProbeURL when ProbeOptions.Timeout is
+//not set.
+const DefaultProbeTimeout = 10 * time.Second
+
+//DevGetURL returns the public url of the device with the given uuid. The url
+//has the form https://<uuid>.<ResinConfig.DeviceURLBase>, ErrDeviceURLDisabled
+//is returned when the device url is not enabled.
+func DevGetURL(ctx *Context, uuid string) (string, error) {
+	dev, err := DevGetByUUID(ctx, uuid)
+	if err != nil {
+		return "", err
+	}
+	if !dev.WebAccessible {
+		return "", ErrDeviceURLDisabled
+	}
+	return devURL(ctx, dev.UUID)
+}
+
+func devURL(ctx *Context, uuid string) (string, error) {
+	cfg, err := ConfigGet(ctx)
+	if err != nil {
+		return "", err
+	}
+	if cfg.DeviceURLBase == "" {
+		return "", errors.New("resingo: missing device url base")
+	}
+	return fmt.Sprintf("https://%s.%s", uuid, strings.TrimPrefix(cfg.DeviceURLBase, ".")), nil
+}
+
+//DevEnablePublicURL enables the device url like DevEnableURL and returns the
+//url.
+func DevEnablePublicURL(ctx *Context, uuid string) (string, error) {
+	err := DevEnableURL(ctx, uuid)
+	if err != nil {
+		return "", err
+	}
+	return devURL(ctx, uuid)
+}
+
+//ProbeOptions configures DevProbeURL.
+type ProbeOptions struct {
+	// Path is appended to the device url, for instance /health.
+	Path string
+
+	// Timeout of the whole request, defaults to DefaultProbeTimeout.
+	Timeout time.Duration
+
+	// Healthy decides if the status code means the device is healthy. By
+	// default all 2xx and 3xx codes are healthy.
+	Healthy func(status int) bool
+
+	// Client sends the probe, it defaults to a http.Client with the timeout.
+	// The client of the context is never used, it may cache responses and
+	// its requests are reported to the hooks as requests to the resin API.
+	Client HTTPClient
+}
+
+//ProbeResult is the result of probing the url of a device.
+type ProbeResult struct {
+	URL        string
+	StatusCode int
+	Latency    time.Duration
+	Healthy    bool
+
+	// Err is the error that made the probe fail, the device is not healthy
+	// when it is set.
+	Err error
+}
+
+//DevProbeURL sends a GET request to the public url of the device with the
+//given uuid and reports the status and latency of the response.
+//
+// Failing to reach the device is not an error, it is reported in the result.
+// The error is only set when the device url can't be determined. The probe is
+// sent with ctx.Parent, so it stops when the parent is done.
+//
+//	res, err := DevProbeURL(ctx, uuid, &ProbeOptions{Path: "/health"})
+//	if err != nil {
+//		// handle error
+//	}
+//	if !res.Healthy {
+//		fmt.Printf("%s is down: %d %v\n", res.URL, res.StatusCode, res.Err)
+//	}
+func DevProbeURL(ctx *Context, uuid string, opts *ProbeOptions) (*ProbeResult, error) {
+	if opts == nil {
+		opts = &ProbeOptions{}
+	}
+	u, err := DevGetURL(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Path != "" {
+		u += "/" + strings.TrimPrefix(opts.Path, "/")
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+	healthy := opts.Healthy
+	if healthy == nil {
+		healthy = func(status int) bool {
+			return status >= 200 && status < 400
+		}
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+	rst := &ProbeResult{URL: u}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	parent := ctx.Parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	c, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(c))
+	if err != nil {
+		rst.Latency = time.Since(start)
+		rst.Err = err
+		return rst, nil
+	}
+	rst.Latency = time.Since(start)
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	rst.StatusCode = resp.StatusCode
+	rst.Healthy = healthy(resp.StatusCode)
+	return rst, nil
+}
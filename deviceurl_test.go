@@ -0,0 +1,164 @@
+package resingo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rewriteTransport sends all requests to the test server, so device urls
+// like https://<uuid>.resindevice.io reach it.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (r *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set("X-Original-Host", req.URL.Host)
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDevURL(t *testing.T) {
+	devices := []*Device{
+		{ID: 1, UUID: "aaa", WebAccessible: true},
+		{ID: 2, UUID: "bbb", WebAccessible: false},
+		{ID: 3, UUID: "ccc", WebAccessible: true},
+	}
+	down := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Header.Get("X-Original-Host")
+		switch {
+		case r.URL.Path == "/v1/device":
+			var rst []*Device
+			for _, d := range devices {
+				if strings.Contains(r.URL.RawQuery, d.UUID) {
+					rst = append(rst, d)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": rst})
+		case host == "aaa.resindevice.io" && r.URL.Path == "/health":
+			if down {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		case host == "ccc.resindevice.io":
+			time.Sleep(50 * time.Millisecond)
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	probe := &http.Client{Transport: &rewriteTransport{target: target}}
+	var probed []string
+	ctx := &Context{
+		Client: NewCachingClient(probe, nil),
+		Config: &Config{ResinEndpoint: "https://api.resin.io"},
+		ConfigCache: &ConfigCache{
+			cfg:     &ResinConfig{DeviceURLBase: "resindevice.io"},
+			fetched: time.Now(),
+		},
+		Hooks: &Hooks{OnRequest: func(ev *RequestEvent) {
+			if strings.Contains(ev.URL, "resindevice.io") {
+				probed = append(probed, ev.URL)
+			}
+		}},
+	}
+	u, err := DevGetURL(ctx, "aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != "https://aaa.resindevice.io" {
+		t.Errorf("expected https://aaa.resindevice.io got %s", u)
+	}
+	_, err = DevGetURL(ctx, "bbb")
+	if err != ErrDeviceURLDisabled {
+		t.Errorf("expected %v got %v", ErrDeviceURLDisabled, err)
+	}
+	sample := []struct {
+		uuid    string
+		opts    *ProbeOptions
+		status  int
+		healthy bool
+		err     bool
+	}{
+		{"aaa", &ProbeOptions{Path: "/health"}, http.StatusOK, true, false},
+		{"aaa", &ProbeOptions{}, http.StatusNotFound, false, false},
+		{"ccc", &ProbeOptions{}, http.StatusServiceUnavailable, false, false},
+		{"ccc", &ProbeOptions{Timeout: 10 * time.Millisecond}, 0, false, true},
+	}
+	for _, v := range sample {
+		v.opts.Client = probe
+		res, err := DevProbeURL(ctx, v.uuid, v.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != v.status || res.Healthy != v.healthy || (res.Err != nil) != v.err {
+			t.Errorf("%s: unexpected result %+v", v.uuid, res)
+		}
+		if res.Latency <= 0 {
+			t.Errorf("%s: expected latency", v.uuid)
+		}
+	}
+	_, err = DevProbeURL(ctx, "bbb", nil)
+	if err != ErrDeviceURLDisabled {
+		t.Errorf("expected %v got %v", ErrDeviceURLDisabled, err)
+	}
+
+	// the caching client of the context must not serve a stale status.
+	down = true
+	res, err := DevProbeURL(ctx, "aaa", &ProbeOptions{Path: "/health", Client: probe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable || res.Healthy {
+		t.Errorf("expected the device to be down got %+v", res)
+	}
+	if len(probed) != 0 {
+		t.Errorf("expected probes to bypass the hooks got %v", probed)
+	}
+
+	// probes are sent with the parent context.
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "trace"))
+	ctx.Parent = parent
+	rec := &contextClient{HTTPClient: probe}
+	if _, err = DevProbeURL(ctx, "aaa", &ProbeOptions{Client: rec}); err != nil {
+		t.Fatal(err)
+	}
+	if rec.ctx == nil || rec.ctx.Value(key{}) != "trace" {
+		t.Error("expected the probe to carry the parent context")
+	}
+	rec = &contextClient{HTTPClient: probe, before: cancel}
+	res, err = DevProbeURL(ctx, "ccc", &ProbeOptions{Client: rec, Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(res.Err, context.Canceled) {
+		t.Errorf("expected the probe to be canceled got %+v", res)
+	}
+}
+
+// records the context of the last request, and calls before when it is set.
+type contextClient struct {
+	HTTPClient
+	ctx    context.Context
+	before func()
+}
+
+func (c *contextClient) Do(req *http.Request) (*http.Response, error) {
+	c.ctx = req.Context()
+	if c.before != nil {
+		c.before()
+	}
+	return c.HTTPClient.Do(req)
+}
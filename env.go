@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 )
 
 //Env contains the response for device environment variable
@@ -42,7 +43,14 @@ type AppEnv struct {
 }
 
 //EnvDevCreate creates environment variable for the device
+//
+// Names starting with ConfigVarPrefix are rejected with ErrReservedEnvVar, use
+// ConfigVarDevCreate for config variables.
 func EnvDevCreate(ctx *Context, id int64, key, value string) (*Env, error) {
+	err := ValidateEnvVarName(key)
+	if err != nil {
+		return nil, err
+	}
 	h := authHeader(ctx.Config.AuthToken)
 	uri := ctx.Config.APIEndpoint("device_environment_variable")
 	data := make(map[string]interface{})
@@ -145,7 +153,14 @@ func EnvAppGetAll(ctx *Context, id int64) ([]*AppEnv, error) {
 }
 
 //EnvAppCreate creates a newapplication environment variable
+//
+// Names starting with ConfigVarPrefix are rejected with ErrReservedEnvVar, use
+// ConfigVarAppCreate for config variables.
 func EnvAppCreate(ctx *Context, id int64, key, value string) (*AppEnv, error) {
+	err := ValidateEnvVarName(key)
+	if err != nil {
+		return nil, err
+	}
 	h := authHeader(ctx.Config.AuthToken)
 	uri := ctx.Config.APIEndpoint("environment_variable")
 	data := make(map[string]interface{})
@@ -203,3 +218,115 @@ func EnvAppDelete(ctx *Context, id int64) error {
 	}
 	return nil
 }
+
+//EnvSource tells where the effective value of a device variable comes from.
+type EnvSource int
+
+// sources of device variables
+const (
+	EnvFromApplication EnvSource = iota
+	EnvFromDevice
+)
+
+func (s EnvSource) String() string {
+	switch s {
+	case EnvFromApplication:
+		return "application"
+	case EnvFromDevice:
+		return "device"
+	}
+	return "Unknown"
+}
+
+//EffectiveEnv is a variable as seen by a device.
+//
+// ID is the id of the variable in its source collection. When the device
+// overrides an application variable, AppValue holds the value of the
+// application variable.
+type EffectiveEnv struct {
+	ID         int64
+	Name       string
+	Value      string
+	Source     EnvSource
+	Config     bool
+	Overridden bool
+	AppValue   string
+}
+
+//EnvDevEffective returns the variables that the device with the given uuid
+//actually sees. Application variables are merged with the device variables,
+//with device variables taking precedence. Both environment and config
+//variables are included, the result is sorted by name.
+func EnvDevEffective(ctx *Context, uuid string) ([]*EffectiveEnv, error) {
+	dev, err := DevGetByUUID(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	appEnv, err := EnvAppGetAll(ctx, dev.Application.ID)
+	if err != nil {
+		return nil, err
+	}
+	devEnv, err := EnvDevGetAll(ctx, dev.ID)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := ConfigVarAppGetAll(ctx, dev.Application.ID)
+	if err != nil {
+		return nil, err
+	}
+	devCfg, err := ConfigVarDevGetAll(ctx, dev.ID)
+	if err != nil {
+		return nil, err
+	}
+	m := newEnvMerge()
+	for _, e := range appEnv {
+		m.set(e.ID, e.Name, e.Value, EnvFromApplication, false)
+	}
+	for _, e := range appCfg {
+		m.set(e.ID, e.Name, e.Value, EnvFromApplication, true)
+	}
+	for _, e := range devEnv {
+		m.set(e.ID, e.Name, e.Value, EnvFromDevice, false)
+	}
+	for _, e := range devCfg {
+		m.set(e.ID, e.Name, e.Value, EnvFromDevice, true)
+	}
+	return m.result(), nil
+}
+
+// merges variables, variables set later take precedence.
+type envMerge struct {
+	vars map[string]*EffectiveEnv
+}
+
+func newEnvMerge() *envMerge {
+	return &envMerge{vars: make(map[string]*EffectiveEnv)}
+}
+
+func (m *envMerge) set(id int64, name, value string, src EnvSource, config bool) {
+	e := &EffectiveEnv{
+		ID:     id,
+		Name:   name,
+		Value:  value,
+		Source: src,
+		Config: config,
+	}
+	if old, ok := m.vars[name]; ok && old.Source == EnvFromApplication && src == EnvFromDevice {
+		e.Overridden = true
+		e.AppValue = old.Value
+	}
+	m.vars[name] = e
+}
+
+func (m *envMerge) result() []*EffectiveEnv {
+	var names []string
+	for k := range m.vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	rst := make([]*EffectiveEnv, 0, len(names))
+	for _, n := range names {
+		rst = append(rst, m.vars[n])
+	}
+	return rst
+}
@@ -0,0 +1,42 @@
+package resingo
+
+import "testing"
+
+func TestEnvMerge(t *testing.T) {
+	m := newEnvMerge()
+	m.set(1, "MONIKER", "IOT", EnvFromApplication, false)
+	m.set(2, "Mad", "Scientist", EnvFromApplication, false)
+	m.set(3, "RESIN_HOST_CONFIG_gpu_mem", "64", EnvFromApplication, true)
+	m.set(4, "Mad", "Engineer", EnvFromDevice, false)
+	m.set(5, "RESIN_SUPERVISOR_POLL_INTERVAL", "6000", EnvFromDevice, true)
+	env := m.result()
+	sample := []struct {
+		name, value string
+		src         EnvSource
+		config      bool
+		appValue    string
+	}{
+		{"MONIKER", "IOT", EnvFromApplication, false, ""},
+		{"Mad", "Engineer", EnvFromDevice, false, "Scientist"},
+		{"RESIN_HOST_CONFIG_gpu_mem", "64", EnvFromApplication, true, ""},
+		{"RESIN_SUPERVISOR_POLL_INTERVAL", "6000", EnvFromDevice, true, ""},
+	}
+	if len(env) != len(sample) {
+		t.Fatalf("expected %d variables got %d", len(sample), len(env))
+	}
+	for i, v := range sample {
+		e := env[i]
+		if e.Name != v.name || e.Value != v.value {
+			t.Errorf("expected %s=%s got %s=%s", v.name, v.value, e.Name, e.Value)
+		}
+		if e.Source != v.src {
+			t.Errorf("%s: expected source %s got %s", v.name, v.src, e.Source)
+		}
+		if e.Config != v.config {
+			t.Errorf("%s: expected config %v got %v", v.name, v.config, e.Config)
+		}
+		if e.Overridden != (v.appValue != "") || e.AppValue != v.appValue {
+			t.Errorf("%s: expected application value %q got %q", v.name, v.appValue, e.AppValue)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package resingo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+//EnvFormat is the file format of exported environment variables.
+type EnvFormat int
+
+// supported environment file formats
+const (
+	EnvDotenv EnvFormat = iota
+	EnvJSON
+	EnvYAML
+)
+
+func (f EnvFormat) String() string {
+	switch f {
+	case EnvDotenv:
+		return "dotenv"
+	case EnvJSON:
+		return "json"
+	case EnvYAML:
+		return "yaml"
+	}
+	return "Unknown"
+}
+
+//ErrUnknownEnvFormat is returned for unsupported environment file formats.
+var ErrUnknownEnvFormat = errors.New("resingo: unknown environment file format")
+
+//EnvFormatFromFile returns the format of the environment file with the given
+//name based on its extension.
+func EnvFormatFromFile(name string) (EnvFormat, error) {
+	base := filepath.Base(name)
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".json":
+		return EnvJSON, nil
+	case ".yaml", ".yml":
+		return EnvYAML, nil
+	case ".env":
+		return EnvDotenv, nil
+	}
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return EnvDotenv, nil
+	}
+	return 0, ErrUnknownEnvFormat
+}
+
+//EnvSet is a set of environment variables, mapping names to values. It can be
+//applied with EnvAppSync or EnvDevSync.
+type EnvSet map[string]string
+
+//EnvSetFromApp returns the environment set of application environment
+//variables.
+func EnvSetFromApp(envs []*AppEnv) EnvSet {
+	s := make(EnvSet)
+	for _, e := range envs {
+		s[e.Name] = e.Value
+	}
+	return s
+}
+
+//EnvSetFromDevice returns the environment set of device environment
+//variables.
+func EnvSetFromDevice(envs []*Env) EnvSet {
+	s := make(EnvSet)
+	for _, e := range envs {
+		s[e.Name] = e.Value
+	}
+	return s
+}
+
+//Names returns the sorted names of the variables in the set.
+func (s EnvSet) Names() []string {
+	var rst []string
+	for k := range s {
+		rst = append(rst, k)
+	}
+	sort.Strings(rst)
+	return rst
+}
+
+//Encode writes the set to w in the given format. Variables are sorted by name
+//so exported files are stable and diff well under version control.
+func (s EnvSet) Encode(w io.Writer, f EnvFormat) error {
+	switch f {
+	case EnvDotenv:
+		var buf bytes.Buffer
+		for _, k := range s.Names() {
+			fmt.Fprintf(&buf, "%s=%s\n", k, dotenvQuote(s[k]))
+		}
+		_, err := buf.WriteTo(w)
+		return err
+	case EnvJSON:
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case EnvYAML:
+		b, err := yaml.Marshal(map[string]string(s))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	return ErrUnknownEnvFormat
+}
+
+//ParseEnvSet reads an environment set in the given format from r.
+//
+// JSON and YAML files must contain a single object, whose values are strings,
+// numbers or booleans. Numbers and booleans are kept as they are written, for
+// instance 1.10 stays 1.10. Dotenv files follow the usual conventions:
+//
+//	# comments and empty lines are ignored
+//	export NAME=value  # the export keyword and trailing comments are allowed
+//	SINGLE='no $escapes \n here'
+//	DOUBLE="supports \n, \t, \" and \\ escapes"
+//	MULTILINE="quoted values
+//	can span multiple lines"
+func ParseEnvSet(r io.Reader, f EnvFormat) (EnvSet, error) {
+	switch f {
+	case EnvDotenv:
+		return parseDotenv(r)
+	case EnvJSON:
+		var v map[string]interface{}
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		err := dec.Decode(&v)
+		if err != nil {
+			return nil, err
+		}
+		return envSetFromValues(v)
+	case EnvYAML:
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		// scalars decoded into strings keep their text, so 1.10 is not
+		// turned into 1.1 nor 0755 into 493.
+		var v map[string]string
+		err = yaml.Unmarshal(b, &v)
+		if err != nil {
+			return nil, err
+		}
+		for k := range v {
+			if !envNameRe.MatchString(k) {
+				return nil, fmt.Errorf("resingo: invalid variable name %q", k)
+			}
+		}
+		return EnvSet(v), nil
+	}
+	return nil, ErrUnknownEnvFormat
+}
+
+func envSetFromValues(v map[string]interface{}) (EnvSet, error) {
+	s := make(EnvSet)
+	for k, val := range v {
+		if !envNameRe.MatchString(k) {
+			return nil, fmt.Errorf("resingo: invalid variable name %q", k)
+		}
+		switch e := val.(type) {
+		case string:
+			s[k] = e
+		case json.Number:
+			s[k] = e.String()
+		case bool:
+			s[k] = strconv.FormatBool(e)
+		case nil:
+			s[k] = ""
+		default:
+			return nil, fmt.Errorf("resingo: variable %s must be a string got %T", k, val)
+		}
+	}
+	return s, nil
+}
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+var dotenvPlainRe = regexp.MustCompile(`^[A-Za-z0-9_./:@,+=-]*$`)
+
+// quotes value for use in a dotenv file. Values which need quoting are double
+// quoted, so they fit in a single line.
+func dotenvQuote(value string) string {
+	if dotenvPlainRe.MatchString(value) {
+		return value
+	}
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		"$", `\$`,
+	)
+	return `"` + r.Replace(value) + `"`
+}
+
+func parseDotenv(r io.Reader) (EnvSet, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &dotenvParser{
+		src:  strings.Replace(string(b), "\r\n", "\n", -1),
+		line: 1,
+	}
+	s := make(EnvSet)
+	for {
+		name, value, err := p.next()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s[name] = value
+	}
+}
+
+type dotenvParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// returns the next variable from the source, io.EOF is returned when there are
+// no more variables.
+func (p *dotenvParser) next() (string, string, error) {
+	for {
+		p.skip(" \t\n")
+		if p.pos == len(p.src) {
+			return "", "", io.EOF
+		}
+		if p.src[p.pos] != '#' {
+			break
+		}
+		p.skipLine()
+	}
+	line := p.line
+	rest := p.src[p.pos:]
+	end := strings.IndexAny(rest, "=\n")
+	if end == -1 || rest[end] != '=' {
+		return "", "", fmt.Errorf("resingo: line %d: missing =", line)
+	}
+	name := strings.TrimSpace(rest[:end])
+	name = strings.TrimSpace(strings.TrimPrefix(name, "export "))
+	if !envNameRe.MatchString(name) {
+		return "", "", fmt.Errorf("resingo: line %d: invalid variable name %q", line, name)
+	}
+	p.pos += end + 1
+	p.skip(" \t")
+	if p.pos == len(p.src) {
+		return name, "", nil
+	}
+	q := p.src[p.pos]
+	if q != '"' && q != '\'' {
+		rest = p.src[p.pos:]
+		if i := strings.IndexByte(rest, '\n'); i != -1 {
+			rest = rest[:i]
+		}
+		p.pos += len(rest)
+		if i := strings.Index(rest, " #"); i != -1 {
+			rest = rest[:i]
+		}
+		return name, strings.TrimSpace(rest), nil
+	}
+	p.pos++
+	rest = p.src[p.pos:]
+	end = dotenvClosingQuote(rest, q)
+	if end == -1 {
+		return "", "", fmt.Errorf("resingo: line %d: unterminated quoted value", line)
+	}
+	value := rest[:end]
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 1
+	tail := p.src[p.pos:]
+	if i := strings.IndexByte(tail, '\n'); i != -1 {
+		tail = tail[:i]
+	}
+	tail = strings.TrimSpace(tail)
+	if tail != "" && !strings.HasPrefix(tail, "#") {
+		return "", "", fmt.Errorf("resingo: line %d: unexpected %q after quoted value", p.line, tail)
+	}
+	p.skipLine()
+	if q == '"' {
+		v, err := dotenvUnescape(value)
+		if err != nil {
+			return "", "", fmt.Errorf("resingo: line %d: %v", line, err)
+		}
+		value = v
+	}
+	return name, value, nil
+}
+
+// skips the characters in chars, counting new lines.
+func (p *dotenvParser) skip(chars string) {
+	for p.pos < len(p.src) && strings.IndexByte(chars, p.src[p.pos]) != -1 {
+		if p.src[p.pos] == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+}
+
+// skips to the beginning of the next line.
+func (p *dotenvParser) skipLine() {
+	i := strings.IndexByte(p.src[p.pos:], '\n')
+	if i == -1 {
+		p.pos = len(p.src)
+		return
+	}
+	p.pos += i + 1
+	p.line++
+}
+
+// returns the index of the unescaped closing quote q in s, or -1.
+func dotenvClosingQuote(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if q == '"' {
+				i++
+			}
+		case q:
+			return i
+		}
+	}
+	return -1
+}
+
+func dotenvUnescape(s string) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			_ = buf.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("trailing backslash")
+		}
+		switch s[i] {
+		case 'n':
+			_ = buf.WriteByte('\n')
+		case 'r':
+			_ = buf.WriteByte('\r')
+		case 't':
+			_ = buf.WriteByte('\t')
+		case '"', '\\', '$', '\'':
+			_ = buf.WriteByte(s[i])
+		default:
+			return "", fmt.Errorf("unknown escape sequence %s", strconv.Quote(s[i-1:i+1]))
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,188 @@
+package resingo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	src := `# fleet configuration
+MONIKER=IOT
+export Mad = Scientist # trailing comment
+EMPTY=
+HASH=abc#def
+SINGLE='no \n escapes # here'
+DOUBLE="tab\tquote\" dollar\$"
+MULTILINE="first line
+second line"
+CERT='-----BEGIN-----
+abc
+-----END-----'
+
+AFTER=multiline
+`
+	s, err := ParseEnvSet(strings.NewReader(src), EnvDotenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := EnvSet{
+		"MONIKER":   "IOT",
+		"Mad":       "Scientist",
+		"EMPTY":     "",
+		"HASH":      "abc#def",
+		"SINGLE":    `no \n escapes # here`,
+		"DOUBLE":    "tab\tquote\" dollar$",
+		"MULTILINE": "first line\nsecond line",
+		"CERT":      "-----BEGIN-----\nabc\n-----END-----",
+		"AFTER":     "multiline",
+	}
+	testEnvSetEqual(t, expect, s)
+
+	bad := []string{
+		"NOVALUE\n",
+		"1BAD=name\n",
+		"OPEN=\"never closed\n",
+		"TAIL=\"quoted\" garbage\n",
+		"ESCAPE=\"\\q\"\n",
+	}
+	for _, v := range bad {
+		_, err := ParseEnvSet(strings.NewReader(v), EnvDotenv)
+		if err == nil {
+			t.Errorf("expected an error for %q", v)
+		}
+	}
+}
+
+func TestEnvSetRoundTrip(t *testing.T) {
+	s := EnvSet{
+		"MONIKER":   "IOT",
+		"SPACES":    "hello world",
+		"QUOTES":    `she said "hi" and 'bye'`,
+		"MULTILINE": "first line\nsecond line\n",
+		"COMMENT":   "value # not a comment",
+		"BACKSLASH": `C:\resin`,
+		"DOLLAR":    "$HOME",
+		"EMPTY":     "",
+		"NUMBER":    "8080",
+	}
+	for _, f := range []EnvFormat{EnvDotenv, EnvJSON, EnvYAML} {
+		var buf bytes.Buffer
+		err := s.Encode(&buf, f)
+		if err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		p, err := ParseEnvSet(&buf, f)
+		if err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		testEnvSetEqual(t, s, p)
+	}
+}
+
+func TestParseEnvSetScalars(t *testing.T) {
+	sample := []struct {
+		src string
+		f   EnvFormat
+	}{
+		{`{"PORT": 8080, "DEBUG": true, "NAME": "resin"}`, EnvJSON},
+		{"PORT: 8080\nDEBUG: true\nNAME: resin\n", EnvYAML},
+	}
+	expect := EnvSet{"PORT": "8080", "DEBUG": "true", "NAME": "resin"}
+	for _, v := range sample {
+		s, err := ParseEnvSet(strings.NewReader(v.src), v.f)
+		if err != nil {
+			t.Fatalf("%s: %v", v.f, err)
+		}
+		testEnvSetEqual(t, expect, s)
+	}
+	for _, v := range []struct {
+		src string
+		f   EnvFormat
+	}{
+		{`{"NESTED": {"a": 1}}`, EnvJSON},
+		{"NESTED:\n  a: 1\n", EnvYAML},
+		{"NOT-VALID: 1\n", EnvYAML},
+	} {
+		if _, err := ParseEnvSet(strings.NewReader(v.src), v.f); err == nil {
+			t.Errorf("%s: expected an error for %q", v.f, v.src)
+		}
+	}
+}
+
+func TestParseEnvSetLossless(t *testing.T) {
+	sample := []struct {
+		src    string
+		f      EnvFormat
+		expect EnvSet
+	}{
+		{
+			"VERSION: 1.10\nMODE: 0755\nHEX: 0x1F\nBIG: 1000000000000000000000\nON: yes\nEMPTY:\n",
+			EnvYAML,
+			EnvSet{"VERSION": "1.10", "MODE": "0755", "HEX": "0x1F", "BIG": "1000000000000000000000", "ON": "yes", "EMPTY": ""},
+		},
+		{
+			`{"VERSION": 1.10, "BIG": 1000000000000000000000, "EXP": 1e3, "EMPTY": null}`,
+			EnvJSON,
+			EnvSet{"VERSION": "1.10", "BIG": "1000000000000000000000", "EXP": "1e3", "EMPTY": ""},
+		},
+	}
+	for _, v := range sample {
+		s, err := ParseEnvSet(strings.NewReader(v.src), v.f)
+		if err != nil {
+			t.Fatalf("%s: %v", v.f, err)
+		}
+		testEnvSetEqual(t, v.expect, s)
+
+		// values which look like numbers survive encoding in every format.
+		for _, f := range []EnvFormat{EnvDotenv, EnvJSON, EnvYAML} {
+			var buf bytes.Buffer
+			if err = s.Encode(&buf, f); err != nil {
+				t.Fatalf("%s: %v", f, err)
+			}
+			p, err := ParseEnvSet(&buf, f)
+			if err != nil {
+				t.Fatalf("%s: %v", f, err)
+			}
+			testEnvSetEqual(t, v.expect, p)
+		}
+	}
+}
+
+func TestEnvFormatFromFile(t *testing.T) {
+	sample := []struct {
+		name   string
+		expect EnvFormat
+	}{
+		{".env", EnvDotenv},
+		{"fleet/.env.production", EnvDotenv},
+		{"production.env", EnvDotenv},
+		{"env.json", EnvJSON},
+		{"env.yml", EnvYAML},
+		{"env.YAML", EnvYAML},
+	}
+	for _, v := range sample {
+		f, err := EnvFormatFromFile(v.name)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if f != v.expect {
+			t.Errorf("%s: expected %s got %s", v.name, v.expect, f)
+		}
+	}
+	_, err := EnvFormatFromFile("env.toml")
+	if err != ErrUnknownEnvFormat {
+		t.Errorf("expected %v got %v", ErrUnknownEnvFormat, err)
+	}
+}
+
+func testEnvSetEqual(t *testing.T, expect, got EnvSet) {
+	if len(expect) != len(got) {
+		t.Errorf("expected %d variables got %d: %v", len(expect), len(got), got)
+	}
+	for k, v := range expect {
+		if g, ok := got[k]; !ok || g != v {
+			t.Errorf("%s: expected %q got %q", k, v, g)
+		}
+	}
+}
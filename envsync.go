@@ -0,0 +1,266 @@
+package resingo
+
+import (
+	"fmt"
+	"sort"
+)
+
+//EnvAction is the action needed to bring an environment variable to the
+//desired state.
+type EnvAction int
+
+// supported environment variable actions
+const (
+	EnvCreate EnvAction = iota
+	EnvUpdate
+	EnvDelete
+)
+
+func (a EnvAction) String() string {
+	switch a {
+	case EnvCreate:
+		return "create"
+	case EnvUpdate:
+		return "update"
+	case EnvDelete:
+		return "delete"
+	}
+	return "Unknown"
+}
+
+//EnvChange is a single change of an environment sync plan. ID is the id of the
+//existing variable, it is zero for variables that are going to be created.
+type EnvChange struct {
+	Action   EnvAction
+	ID       int64
+	Name     string
+	Value    string
+	OldValue string
+}
+
+func (c *EnvChange) String() string {
+	switch c.Action {
+	case EnvCreate:
+		return fmt.Sprintf("+ %s=%s", c.Name, c.Value)
+	case EnvUpdate:
+		return fmt.Sprintf("~ %s=%s (was %s)", c.Name, c.Value, c.OldValue)
+	case EnvDelete:
+		return fmt.Sprintf("- %s", c.Name)
+	}
+	return ""
+}
+
+//EnvPlan are the changes needed to sync environment variables. Changes are
+//sorted by variable name.
+type EnvPlan struct {
+	Create []*EnvChange
+	Update []*EnvChange
+	Delete []*EnvChange
+}
+
+//Empty returns true if there is nothing to change.
+func (p *EnvPlan) Empty() bool {
+	return len(p.Create) == 0 && len(p.Update) == 0 && len(p.Delete) == 0
+}
+
+//Changes returns all changes of the plan in the order they are applied.
+func (p *EnvPlan) Changes() []*EnvChange {
+	var rst []*EnvChange
+	rst = append(rst, p.Create...)
+	rst = append(rst, p.Update...)
+	rst = append(rst, p.Delete...)
+	return rst
+}
+
+//EnvSyncOptions configures environment variables syncing.
+type EnvSyncOptions struct {
+	// Delete removes existing variables which are not in the desired state.
+	// They are left untouched by default.
+	Delete bool
+
+	// DryRun only computes the plan, nothing is changed.
+	DryRun bool
+}
+
+// the current state of a single environment variable.
+type envState struct {
+	id    int64
+	value string
+}
+
+// computes the plan that turns current into desired.
+func envDiff(current map[string]envState, desired map[string]string, del bool) *EnvPlan {
+	p := &EnvPlan{}
+	for name, value := range desired {
+		cur, ok := current[name]
+		if !ok {
+			p.Create = append(p.Create, &EnvChange{Action: EnvCreate, Name: name, Value: value})
+			continue
+		}
+		if cur.value != value {
+			p.Update = append(p.Update, &EnvChange{
+				Action:   EnvUpdate,
+				ID:       cur.id,
+				Name:     name,
+				Value:    value,
+				OldValue: cur.value,
+			})
+		}
+	}
+	if del {
+		for name, cur := range current {
+			if _, ok := desired[name]; !ok {
+				p.Delete = append(p.Delete, &EnvChange{
+					Action:   EnvDelete,
+					ID:       cur.id,
+					Name:     name,
+					OldValue: cur.value,
+				})
+			}
+		}
+	}
+	for _, c := range [][]*EnvChange{p.Create, p.Update, p.Delete} {
+		sort.Sort(envChanges(c))
+	}
+	return p
+}
+
+type envChanges []*EnvChange
+
+func (e envChanges) Len() int           { return len(e) }
+func (e envChanges) Less(i, j int) bool { return e[i].Name < e[j].Name }
+func (e envChanges) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func validateEnvVars(vars map[string]string) error {
+	for name := range vars {
+		if err := ValidateEnvVarName(name); err != nil {
+			return fmt.Errorf("%v: %s", err, name)
+		}
+	}
+	return nil
+}
+
+//EnvDevSet sets the device environment variable name to value, the variable is
+//created if it doesn't exist yet.
+func EnvDevSet(ctx *Context, devID int64, name, value string) (*Env, error) {
+	envs, err := EnvDevGetAll(ctx, devID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range envs {
+		if e.Name == name {
+			if e.Value != value {
+				err = EnvDevUpdate(ctx, e.ID, value)
+				if err != nil {
+					return nil, err
+				}
+				e.Value = value
+			}
+			return e, nil
+		}
+	}
+	return EnvDevCreate(ctx, devID, name, value)
+}
+
+//EnvAppSet sets the application environment variable name to value, the
+//variable is created if it doesn't exist yet.
+func EnvAppSet(ctx *Context, appID int64, name, value string) (*AppEnv, error) {
+	envs, err := EnvAppGetAll(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range envs {
+		if e.Name == name {
+			if e.Value != value {
+				err = EnvAppUpdate(ctx, e.ID, value)
+				if err != nil {
+					return nil, err
+				}
+				e.Value = value
+			}
+			return e, nil
+		}
+	}
+	return EnvAppCreate(ctx, appID, name, value)
+}
+
+//EnvAppSync brings the environment variables of the application with the
+//given appID to the state described by vars, and returns the plan that was
+//applied.
+//
+// When applying the plan fails, the returned error describes the change that
+// failed. Changes before it were applied.
+func EnvAppSync(ctx *Context, appID int64, vars map[string]string, opts *EnvSyncOptions) (*EnvPlan, error) {
+	if opts == nil {
+		opts = &EnvSyncOptions{}
+	}
+	err := validateEnvVars(vars)
+	if err != nil {
+		return nil, err
+	}
+	envs, err := EnvAppGetAll(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]envState)
+	for _, e := range envs {
+		current[e.Name] = envState{id: e.ID, value: e.Value}
+	}
+	p := envDiff(current, vars, opts.Delete)
+	if opts.DryRun {
+		return p, nil
+	}
+	for _, c := range p.Changes() {
+		switch c.Action {
+		case EnvCreate:
+			_, err = EnvAppCreate(ctx, appID, c.Name, c.Value)
+		case EnvUpdate:
+			err = EnvAppUpdate(ctx, c.ID, c.Value)
+		case EnvDelete:
+			err = EnvAppDelete(ctx, c.ID)
+		}
+		if err != nil {
+			return p, fmt.Errorf("resingo: failed to %s %s: %v", c.Action, c.Name, err)
+		}
+	}
+	return p, nil
+}
+
+//EnvDevSync brings the environment variables of the device with the given
+//devID to the state described by vars, and returns the plan that was applied.
+//See EnvAppSync.
+func EnvDevSync(ctx *Context, devID int64, vars map[string]string, opts *EnvSyncOptions) (*EnvPlan, error) {
+	if opts == nil {
+		opts = &EnvSyncOptions{}
+	}
+	err := validateEnvVars(vars)
+	if err != nil {
+		return nil, err
+	}
+	envs, err := EnvDevGetAll(ctx, devID)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]envState)
+	for _, e := range envs {
+		current[e.Name] = envState{id: e.ID, value: e.Value}
+	}
+	p := envDiff(current, vars, opts.Delete)
+	if opts.DryRun {
+		return p, nil
+	}
+	for _, c := range p.Changes() {
+		switch c.Action {
+		case EnvCreate:
+			_, err = EnvDevCreate(ctx, devID, c.Name, c.Value)
+		case EnvUpdate:
+			err = EnvDevUpdate(ctx, c.ID, c.Value)
+		case EnvDelete:
+			err = EnvDevDelete(ctx, c.ID)
+		}
+		if err != nil {
+			return p, fmt.Errorf("resingo: failed to %s %s: %v", c.Action, c.Name, err)
+		}
+	}
+	return p, nil
+}
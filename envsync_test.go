@@ -0,0 +1,95 @@
+package resingo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEnvDiff(t *testing.T) {
+	current := map[string]envState{
+		"MONIKER": {id: 1, value: "IOT"},
+		"Mad":     {id: 2, value: "Scientist"},
+		"Stale":   {id: 3, value: "yes"},
+	}
+	desired := map[string]string{
+		"MONIKER": "IOT",
+		"Mad":     "Engineer",
+		"Around":  "TheWorld",
+		"Bravo":   "Two",
+	}
+	p := envDiff(current, desired, false)
+	if len(p.Create) != 2 || p.Create[0].Name != "Around" || p.Create[1].Name != "Bravo" {
+		t.Errorf("expected to create Around and Bravo got %v", p.Create)
+	}
+	if len(p.Update) != 1 || p.Update[0].ID != 2 || p.Update[0].OldValue != "Scientist" {
+		t.Errorf("expected to update Mad got %v", p.Update)
+	}
+	if len(p.Delete) != 0 {
+		t.Errorf("expected no deletes got %v", p.Delete)
+	}
+
+	p = envDiff(current, desired, true)
+	if len(p.Delete) != 1 || p.Delete[0].ID != 3 {
+		t.Errorf("expected to delete Stale got %v", p.Delete)
+	}
+	if len(p.Changes()) != 4 {
+		t.Errorf("expected 4 changes got %d", len(p.Changes()))
+	}
+
+	p = envDiff(current, map[string]string{"MONIKER": "IOT", "Mad": "Scientist", "Stale": "yes"}, true)
+	if !p.Empty() {
+		t.Errorf("expected empty plan got %v", p.Changes())
+	}
+}
+
+func TestEnvSync(t *testing.T) {
+	config := &Config{
+		Username:      ENV.Username,
+		Password:      ENV.Password,
+		ResinEndpoint: apiEndpoint,
+	}
+	client := &http.Client{}
+	ctx := &Context{
+		Client: client,
+		Config: config,
+	}
+	err := Login(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := AppCreate(ctx, "envsync_test", RaspberryPi3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = AppDelete(ctx, app.ID)
+	}()
+	t.Run("Set", func(ts *testing.T) {
+		for _, v := range []string{"first", "second"} {
+			e, err := EnvAppSet(ctx, app.ID, "MONIKER", v)
+			if err != nil {
+				ts.Fatal(err)
+			}
+			if e.Value != v {
+				ts.Errorf("expected %s got %s", v, e.Value)
+			}
+		}
+	})
+	t.Run("Sync", func(ts *testing.T) {
+		vars := map[string]string{"Around": "TheWorld"}
+		p, err := EnvAppSync(ctx, app.ID, vars, &EnvSyncOptions{Delete: true})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if len(p.Create) != 1 || len(p.Delete) != 1 {
+			ts.Errorf("expected one create and one delete got %v", p.Changes())
+		}
+		p, err = EnvAppSync(ctx, app.ID, vars, &EnvSyncOptions{Delete: true})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if !p.Empty() {
+			ts.Errorf("expected nothing to change got %v", p.Changes())
+		}
+	})
+}
@@ -0,0 +1,462 @@
+package resingo
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//EventSignatureHeader is the header carrying the signature of an event, see
+//EventSign.
+const EventSignatureHeader = "X-Resingo-Signature"
+
+// maximum size of an event body accepted by the receiver.
+const maxEventSize = 1 << 20
+
+//DefaultEventTolerance is how far the time of an event can be from the time it
+//is received when EventReceiver.Tolerance is not set.
+const DefaultEventTolerance = 5 * time.Minute
+
+//EventType is the type of an event.
+type EventType string
+
+//Types of the events with a typed payload.
+const (
+	EventDeviceOnline    EventType = "device.online"
+	EventDeviceOffline   EventType = "device.offline"
+	EventEnvChanged      EventType = "env.changed"
+	EventReleaseDeployed EventType = "release.deployed"
+)
+
+var (
+	//ErrBadSignature is returned when the signature of an event is missing or
+	//doesn't match its body.
+	ErrBadSignature = errors.New("resingo: bad event signature")
+
+	//ErrBadEvent is returned when an event or its payload can't be decoded.
+	ErrBadEvent = errors.New("resingo: bad event")
+
+	//ErrEventExpired is returned when the time of an event is outside the
+	//tolerance of the receiver.
+	ErrEventExpired = errors.New("resingo: event time outside the tolerance")
+)
+
+//Event is a change of the state of the resin API, sent to an EventReceiver by
+//a poller or any other source.
+type Event struct {
+	// ID identifies the event, it is optional. Events without a typed payload
+	// are deduplicated by their ID.
+	ID   string    `json:"id,omitempty"`
+	Type EventType `json:"type"`
+
+	// Time is when the state was observed, it is required. It orders the
+	// events of a device, variable or application and protects against
+	// replays.
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+//DeviceEvent is the payload of EventDeviceOnline and EventDeviceOffline.
+type DeviceEvent struct {
+	UUID  string `json:"uuid"`
+	Name  string `json:"name,omitempty"`
+	AppID int64  `json:"application_id,omitempty"`
+}
+
+//EnvEvent is the payload of EventEnvChanged. DeviceUUID is empty for
+//application variables.
+type EnvEvent struct {
+	AppID      int64  `json:"application_id"`
+	DeviceUUID string `json:"device_uuid,omitempty"`
+	Name       string `json:"name"`
+	Value      string `json:"value,omitempty"`
+	Deleted    bool   `json:"deleted,omitempty"`
+}
+
+//ReleaseEvent is the payload of EventReleaseDeployed.
+type ReleaseEvent struct {
+	AppID     int64  `json:"application_id"`
+	ReleaseID int64  `json:"release_id,omitempty"`
+	Commit    string `json:"commit"`
+}
+
+//NewEvent returns an event of the given type with payload as its data.
+func NewEvent(typ EventType, payload interface{}) (*Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{Type: typ, Time: time.Now(), Data: b}, nil
+}
+
+//EventSign returns the signature of the event body, which is the hex encoded
+//HMAC-SHA256 of the body with the shared secret prefixed by sha256=.
+func EventSign(secret, body []byte) string {
+	m := hmac.New(sha256.New, secret)
+	_, _ = m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+//EventPost sends the signed event e to the EventReceiver at uri.
+func EventPost(client HTTPClient, uri string, secret []byte, e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventSignatureHeader, EventSign(secret, b))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("resingo: [%d ] %s : %s", resp.StatusCode, uri, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+//EventState is the last state delivered for a key, and the time of the event
+//that carried it.
+type EventState struct {
+	State string
+	Time  time.Time
+}
+
+//EventStore stores the last state delivered for each key. The receiver uses it
+//to tell real changes from the same poll results sent again, and to drop
+//events older than the stored state, so a store shared by many receivers
+//deduplicates across them.
+type EventStore interface {
+	// Get returns the state stored under key, it is nil when there is none.
+	Get(key string) (*EventState, error)
+
+	// Put stores the state under key. When ttl is not zero the state can be
+	// forgotten once ttl has passed.
+	Put(key string, s *EventState, ttl time.Duration) error
+}
+
+// how often the MemoryEventStore removes expired states.
+const memoryEventStoreSweep = time.Minute
+
+//MemoryEventStore is an EventStore keeping the states in memory. Expired
+//states are removed, so its size is bounded by the number of devices,
+//variables and applications, and the events received during their ttl.
+type MemoryEventStore struct {
+	mu     sync.Mutex
+	states map[string]*memoryEventState
+	swept  time.Time
+	now    func() time.Time
+}
+
+type memoryEventState struct {
+	EventState
+	expires time.Time
+}
+
+//NewMemoryEventStore returns an empty MemoryEventStore.
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		states: make(map[string]*memoryEventState),
+		now:    time.Now,
+	}
+}
+
+//Get implements EventStore.
+func (m *MemoryEventStore) Get(key string) (*EventState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.states[key]
+	if !ok || m.expired(s, m.now()) {
+		return nil, nil
+	}
+	rst := s.EventState
+	return &rst, nil
+}
+
+//Put implements EventStore.
+func (m *MemoryEventStore) Put(key string, s *EventState, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	e := &memoryEventState{EventState: *s}
+	if ttl != 0 {
+		e.expires = now.Add(ttl)
+	}
+	m.states[key] = e
+	if now.Sub(m.swept) >= memoryEventStoreSweep {
+		for k, v := range m.states {
+			if m.expired(v, now) {
+				delete(m.states, k)
+			}
+		}
+		m.swept = now
+	}
+	return nil
+}
+
+func (m *MemoryEventStore) expired(s *memoryEventState, now time.Time) bool {
+	return !s.expires.IsZero() && !now.Before(s.expires)
+}
+
+// serializes the events of a key, the locks are removed when they are not
+// used.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (k *keyLocks) lock(key string) {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+	l.Lock()
+}
+
+func (k *keyLocks) unlock(key string) {
+	k.mu.Lock()
+	l := k.locks[key]
+	l.refs--
+	if l.refs == 0 {
+		delete(k.locks, key)
+	}
+	k.mu.Unlock()
+	l.Unlock()
+}
+
+type eventHandler func(*Event, interface{}) error
+
+//EventReceiver is a http.Handler receiving signed events and dispatching them
+//to the registered handlers.
+//
+// Events whose time is further than Tolerance from the time they are received
+// are rejected, so captured events can't be replayed later. Events older than
+// the last one delivered for the same device, variable or application are
+// dropped, and so are events carrying the same state, which are duplicates.
+// The state is stored only after all handlers succeed, when a handler fails
+// the receiver responds with 500 so the sender can retry.
+//
+// Events for the same key are handled one at a time, events for different
+// keys are handled concurrently, so handlers must be safe for concurrent use.
+// Handlers must be registered before the receiver serves requests.
+//
+//	r := NewEventReceiver(secret, nil)
+//	r.HandleDevice(func(e *Event, d *DeviceEvent) error {
+//		log.Println(d.UUID, e.Type)
+//		return nil
+//	})
+//	http.Handle("/events", r)
+type EventReceiver struct {
+	// Tolerance is how far the time of an event can be from the time it is
+	// received, it defaults to DefaultEventTolerance.
+	Tolerance time.Duration
+
+	secret   []byte
+	store    EventStore
+	handlers map[EventType][]eventHandler
+	locks    keyLocks
+	now      func() time.Time
+}
+
+//NewEventReceiver returns a receiver accepting events signed with secret. The
+//states are kept in a MemoryEventStore when store is nil.
+func NewEventReceiver(secret []byte, store EventStore) *EventReceiver {
+	if store == nil {
+		store = NewMemoryEventStore()
+	}
+	return &EventReceiver{
+		secret:   secret,
+		store:    store,
+		handlers: make(map[EventType][]eventHandler),
+		locks:    keyLocks{locks: make(map[string]*keyLock)},
+		now:      time.Now,
+	}
+}
+
+func (r *EventReceiver) add(h eventHandler, types ...EventType) {
+	for _, typ := range types {
+		r.handlers[typ] = append(r.handlers[typ], h)
+	}
+}
+
+//HandleDevice registers h for EventDeviceOnline and EventDeviceOffline.
+func (r *EventReceiver) HandleDevice(h func(*Event, *DeviceEvent) error) {
+	r.add(func(e *Event, p interface{}) error {
+		return h(e, p.(*DeviceEvent))
+	}, EventDeviceOnline, EventDeviceOffline)
+}
+
+//HandleEnv registers h for EventEnvChanged.
+func (r *EventReceiver) HandleEnv(h func(*Event, *EnvEvent) error) {
+	r.add(func(e *Event, p interface{}) error {
+		return h(e, p.(*EnvEvent))
+	}, EventEnvChanged)
+}
+
+//HandleRelease registers h for EventReleaseDeployed.
+func (r *EventReceiver) HandleRelease(h func(*Event, *ReleaseEvent) error) {
+	r.add(func(e *Event, p interface{}) error {
+		return h(e, p.(*ReleaseEvent))
+	}, EventReleaseDeployed)
+}
+
+//Handle registers h for events of the given type, the payload is left in
+//e.Data.
+func (r *EventReceiver) Handle(typ EventType, h func(*Event) error) {
+	r.add(func(e *Event, _ interface{}) error {
+		return h(e)
+	}, typ)
+}
+
+//ServeHTTP implements http.Handler. It responds with 204 when the event was
+//handled or dropped, 401 when the signature is bad and 400 when the event
+//can't be decoded or its time is outside the tolerance.
+func (r *EventReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxEventSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !r.verify(req.Header.Get(EventSignatureHeader), b) {
+		http.Error(w, ErrBadSignature.Error(), http.StatusUnauthorized)
+		return
+	}
+	e := &Event{}
+	if err = json.Unmarshal(b, e); err != nil || e.Type == "" {
+		http.Error(w, ErrBadEvent.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err = r.dispatch(e)
+	switch {
+	case err == ErrBadEvent || err == ErrEventExpired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (r *EventReceiver) verify(sig string, body []byte) bool {
+	if sig == "" {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(EventSign(r.secret, body)))
+}
+
+func (r *EventReceiver) tolerance() time.Duration {
+	if r.Tolerance > 0 {
+		return r.Tolerance
+	}
+	return DefaultEventTolerance
+}
+
+// dispatches e to its handlers and reports whether it was dropped, because it
+// is a duplicate or older than the stored state.
+func (r *EventReceiver) dispatch(e *Event) (bool, error) {
+	tolerance := r.tolerance()
+	if d := r.now().Sub(e.Time); d > tolerance || d < -tolerance {
+		return false, ErrEventExpired
+	}
+	payload, key, state, err := decodeEvent(e)
+	if err != nil {
+		return false, err
+	}
+	var ttl time.Duration
+	if strings.HasPrefix(key, "id:") {
+		// an event is accepted until tolerance after its time, which is at
+		// most tolerance after it was stored.
+		ttl = 2 * tolerance
+	}
+	if key != "" {
+		r.locks.lock(key)
+		defer r.locks.unlock(key)
+		prev, err := r.store.Get(key)
+		if err != nil {
+			return false, err
+		}
+		if prev != nil && e.Time.Before(prev.Time) {
+			return true, nil
+		}
+		if prev != nil && prev.State == state {
+			// the newer time makes retries of older events stale.
+			return true, r.store.Put(key, &EventState{State: state, Time: e.Time}, ttl)
+		}
+	}
+	for _, h := range r.handlers[e.Type] {
+		if err := h(e, payload); err != nil {
+			return false, err
+		}
+	}
+	if key == "" {
+		return false, nil
+	}
+	return false, r.store.Put(key, &EventState{State: state, Time: e.Time}, ttl)
+}
+
+// decodes the payload of e, and returns the key and the state it is stored
+// under for deduplication. The key is empty when e can't be deduplicated.
+func decodeEvent(e *Event) (payload interface{}, key, state string, err error) {
+	switch e.Type {
+	case EventDeviceOnline, EventDeviceOffline:
+		d := &DeviceEvent{}
+		if json.Unmarshal(e.Data, d) != nil || d.UUID == "" {
+			return nil, "", "", ErrBadEvent
+		}
+		return d, "device:" + d.UUID, string(e.Type), nil
+	case EventEnvChanged:
+		v := &EnvEvent{}
+		if json.Unmarshal(e.Data, v) != nil || v.Name == "" {
+			return nil, "", "", ErrBadEvent
+		}
+		key = fmt.Sprintf("env:%d:%s:%s", v.AppID, v.DeviceUUID, v.Name)
+		if v.Deleted {
+			return v, key, "deleted", nil
+		}
+
+		// only a hash of the value is stored, values may be secrets.
+		sum := sha256.Sum256([]byte(v.Value))
+		return v, key, hex.EncodeToString(sum[:]), nil
+	case EventReleaseDeployed:
+		rel := &ReleaseEvent{}
+		if json.Unmarshal(e.Data, rel) != nil || rel.Commit == "" {
+			return nil, "", "", ErrBadEvent
+		}
+		return rel, fmt.Sprintf("release:%d", rel.AppID), rel.Commit, nil
+	}
+	if e.ID != "" {
+		return nil, "id:" + e.ID, "seen", nil
+	}
+	return nil, "", "", nil
+}
@@ -0,0 +1,243 @@
+package resingo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventSign(t *testing.T) {
+	// from RFC 4231 test case 2
+	sig := EventSign([]byte("Jefe"), []byte("what do ya want for nothing?"))
+	expect := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if sig != expect {
+		t.Errorf("expected %s got %s", expect, sig)
+	}
+}
+
+func TestEventReceiver(t *testing.T) {
+	secret := []byte("secret")
+	store := NewMemoryEventStore()
+	r := NewEventReceiver(secret, store)
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	store.now = r.now
+	var (
+		mu   sync.Mutex
+		got  []string
+		fail bool
+	)
+	record := func(s string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("boom")
+		}
+		got = append(got, s)
+		return nil
+	}
+	r.HandleDevice(func(e *Event, d *DeviceEvent) error {
+		return record(string(e.Type) + " " + d.UUID)
+	})
+	r.HandleEnv(func(e *Event, v *EnvEvent) error {
+		return record("env " + v.Name + "=" + v.Value)
+	})
+	r.HandleRelease(func(e *Event, rel *ReleaseEvent) error {
+		return record("release " + rel.Commit)
+	})
+	r.Handle("custom", func(e *Event) error {
+		return record("custom " + e.ID)
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// events are observed one second apart, in the minute before now.
+	tick := 0
+	event := func(typ EventType, payload interface{}) *Event {
+		e, err := NewEvent(typ, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tick++
+		e.Time = now.Add(time.Duration(tick)*time.Second - time.Minute)
+		return e
+	}
+	post := func(e *Event) error {
+		mu.Lock()
+		got = nil
+		mu.Unlock()
+		return EventPost(ts.Client(), ts.URL, secret, e)
+	}
+	sample := []struct {
+		event  *Event
+		expect string
+	}{
+		{event(EventDeviceOnline, &DeviceEvent{UUID: "aaa"}), "device.online aaa"},
+		{event(EventDeviceOnline, &DeviceEvent{UUID: "aaa"}), ""},
+		{event(EventDeviceOnline, &DeviceEvent{UUID: "bbb"}), "device.online bbb"},
+		{event(EventDeviceOffline, &DeviceEvent{UUID: "aaa"}), "device.offline aaa"},
+		{event(EventEnvChanged, &EnvEvent{AppID: 1, Name: "TOKEN", Value: "hush"}), "env TOKEN=hush"},
+		{event(EventEnvChanged, &EnvEvent{AppID: 1, Name: "TOKEN", Value: "hush"}), ""},
+		{event(EventEnvChanged, &EnvEvent{AppID: 1, DeviceUUID: "aaa", Name: "TOKEN", Value: "hush"}), "env TOKEN=hush"},
+		{event(EventEnvChanged, &EnvEvent{AppID: 1, Name: "TOKEN", Deleted: true}), "env TOKEN="},
+		{event(EventReleaseDeployed, &ReleaseEvent{AppID: 1, Commit: "abc"}), "release abc"},
+		{event(EventReleaseDeployed, &ReleaseEvent{AppID: 1, Commit: "abc"}), ""},
+		{&Event{ID: "1", Type: "custom", Time: now}, "custom 1"},
+		{&Event{ID: "1", Type: "custom", Time: now}, ""},
+		{&Event{Type: "unknown", Time: now}, ""},
+	}
+	for i, v := range sample {
+		if err := post(v.event); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if strings.Join(got, ",") != v.expect {
+			t.Errorf("%d: expected %q got %q", i, v.expect, got)
+		}
+	}
+	for k, s := range store.states {
+		if strings.Contains(s.State, "hush") {
+			t.Errorf("expected a hash of the value stored under %s got %s", k, s.State)
+		}
+	}
+
+	fail = true
+	e := event(EventEnvChanged, &EnvEvent{AppID: 1, Name: "TOKEN", Value: "new"})
+	err := post(e)
+	if err == nil || !strings.Contains(err.Error(), "[500 ]") {
+		t.Fatalf("expected a server error got %v", err)
+	}
+	fail = false
+	if err = post(e); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Errorf("expected the failed event to be delivered again got %v", got)
+	}
+
+	// a retry of an event older than the stored state is stale.
+	fail = true
+	online := event(EventDeviceOnline, &DeviceEvent{UUID: "ccc"})
+	if err = post(online); err == nil {
+		t.Fatal("expected a server error")
+	}
+	fail = false
+	if err = post(event(EventDeviceOffline, &DeviceEvent{UUID: "ccc"})); err != nil {
+		t.Fatal(err)
+	}
+	if err = post(online); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected the stale retry to be dropped got %v", got)
+	}
+	if s, _ := store.Get("device:ccc"); s == nil || s.State != string(EventDeviceOffline) {
+		t.Errorf("expected the device to stay offline got %+v", s)
+	}
+
+	// a captured event can't be replayed once it is outside the tolerance.
+	replay, _ := json.Marshal(event(EventDeviceOnline, &DeviceEvent{UUID: "ddd"}))
+	sig := EventSign(secret, replay)
+	status := func(body []byte, sig string) int {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set(EventSignatureHeader, sig)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := status(replay, sig); code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, code)
+	}
+	now = now.Add(10 * time.Minute)
+	got = nil
+	if code := status(replay, sig); code != http.StatusBadRequest || len(got) != 0 {
+		t.Errorf("expected the replay to be rejected got %d %v", code, got)
+	}
+
+	// ids are forgotten after twice the tolerance.
+	if s, _ := store.Get("id:1"); s != nil {
+		t.Errorf("expected the id to expire got %+v", s)
+	}
+
+	future := func(d time.Duration) []byte {
+		b, _ := json.Marshal(&Event{Type: EventDeviceOnline, Time: now.Add(d), Data: json.RawMessage(`{"uuid":"eee"}`)})
+		return b
+	}
+	body := `{"type":"device.online","data":{"uuid":"ccc"}}`
+	bad := []struct {
+		method string
+		body   []byte
+		sig    string
+		status int
+	}{
+		{"GET", nil, "", http.StatusMethodNotAllowed},
+		{"POST", []byte(body), "", http.StatusUnauthorized},
+		{"POST", []byte(body), EventSign([]byte("other"), []byte(body)), http.StatusUnauthorized},
+		{"POST", []byte(body), EventSign(secret, []byte(body)), http.StatusBadRequest},
+		{"POST", []byte(`not json`), EventSign(secret, []byte(`not json`)), http.StatusBadRequest},
+		{"POST", future(time.Hour), EventSign(secret, future(time.Hour)), http.StatusBadRequest},
+		{"POST", future(time.Minute), EventSign(secret, future(time.Minute)), http.StatusNoContent},
+	}
+	for i, v := range bad {
+		req := httptest.NewRequest(v.method, "/", bytes.NewReader(v.body))
+		if v.sig != "" {
+			req.Header.Set(EventSignatureHeader, v.sig)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != v.status {
+			t.Errorf("%d: expected %d got %d", i, v.status, w.Code)
+		}
+	}
+
+	// expired states are removed when a state is stored.
+	if _, ok := store.states["id:1"]; ok {
+		t.Error("expected the expired id to be removed")
+	}
+}
+
+func TestEventReceiverConcurrency(t *testing.T) {
+	r := NewEventReceiver([]byte("secret"), nil)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	r.HandleDevice(func(e *Event, d *DeviceEvent) error {
+		if d.UUID == "slow" {
+			close(entered)
+			<-release
+		}
+		return nil
+	})
+	slow, _ := NewEvent(EventDeviceOnline, &DeviceEvent{UUID: "slow"})
+	done := make(chan error)
+	go func() {
+		_, err := r.dispatch(slow)
+		done <- err
+	}()
+	<-entered
+	fast, _ := NewEvent(EventDeviceOnline, &DeviceEvent{UUID: "fast"})
+	rst := make(chan error)
+	go func() {
+		_, err := r.dispatch(fast)
+		rst <- err
+	}()
+	select {
+	case err := <-rst:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected events of other devices not to wait for a slow handler")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if len(r.locks.locks) != 0 {
+		t.Errorf("expected the locks to be removed got %d", len(r.locks.locks))
+	}
+}
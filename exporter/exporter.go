@@ -0,0 +1,247 @@
+//Package exporter exports the state of a resin fleet as prometheus metrics.
+//
+//	t := exporter.NewTransport(nil)
+//	ctx := &resingo.Context{
+//		Client: &http.Client{Transport: t},
+//		Config: config,
+//	}
+//	// login ...
+//	e := exporter.New(ctx, &exporter.Options{Transport: t})
+//	http.Handle("/metrics", e)
+package exporter
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gernest/resingo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//DefaultCacheInterval is how long the fleet state is reused between scrapes
+//when Options.CacheInterval is not set.
+const DefaultCacheInterval = 30 * time.Second
+
+const namespace = "resin"
+
+var (
+	upDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "up"),
+		"Whether the last refresh of the fleet state from the resin API succeeded.",
+		nil, nil,
+	)
+	devicesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "devices"),
+		"Number of devices per application and connection state.",
+		[]string{"application_id", "application", "state"}, nil,
+	)
+	statusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "devices_by_status"),
+		"Number of devices per application and status.",
+		[]string{"application_id", "application", "status"}, nil,
+	)
+	osVersionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "devices_by_os_version"),
+		"Number of devices per application and resin OS version.",
+		[]string{"application_id", "application", "version"}, nil,
+	)
+	supervisorVersionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "devices_by_supervisor_version"),
+		"Number of devices per application and supervisor version.",
+		[]string{"application_id", "application", "version"}, nil,
+	)
+	lastSeenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "device_last_connectivity_seconds"),
+		"Seconds since the last connectivity event of the device.",
+		[]string{"application_id", "application", "uuid", "name"}, nil,
+	)
+	refreshesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "refreshes_total"),
+		"Number of refreshes of the fleet state from the resin API.",
+		nil, nil,
+	)
+	refreshErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "refresh_errors_total"),
+		"Number of failed refreshes of the fleet state.",
+		nil, nil,
+	)
+	refreshDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "refresh_duration_seconds"),
+		"Duration of the last refresh of the fleet state.",
+		nil, nil,
+	)
+)
+
+//Options configures the Exporter.
+type Options struct {
+	// CacheInterval is how long the fleet state is reused between scrapes,
+	// defaults to DefaultCacheInterval.
+	CacheInterval time.Duration
+
+	// Transport is the transport used by the http client of the context, its
+	// metrics are served together with the fleet metrics when set.
+	Transport *Transport
+}
+
+//Exporter is a prometheus collector and http.Handler serving the metrics of
+//the fleet of the user who is logged in with the context.
+type Exporter struct {
+	ctx      *resingo.Context
+	interval time.Duration
+	handler  http.Handler
+	now      func() time.Time
+
+	mu            sync.Mutex
+	state         *fleetState
+	fetched       time.Time
+	up            bool
+	refreshes     float64
+	refreshErrors float64
+	duration      time.Duration
+}
+
+// devices are keyed by the id of their application, names of applications
+// are not unique.
+type fleetState struct {
+	apps    map[int64]string
+	devices map[int64][]*resingo.Device
+}
+
+//New returns an Exporter for the fleet of the user logged in with ctx.
+func New(ctx *resingo.Context, opts *Options) *Exporter {
+	if opts == nil {
+		opts = &Options{}
+	}
+	e := &Exporter{
+		ctx:      ctx,
+		interval: opts.CacheInterval,
+		now:      time.Now,
+	}
+	if e.interval == 0 {
+		e.interval = DefaultCacheInterval
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+	if opts.Transport != nil {
+		reg.MustRegister(opts.Transport)
+	}
+	e.handler = promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	return e
+}
+
+//ServeHTTP serves the metrics in the prometheus text format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// collectors are gathered concurrently, refreshing first makes the
+	// transport metrics include the requests of this refresh.
+	e.mu.Lock()
+	e.refreshIfStale()
+	e.mu.Unlock()
+	e.handler.ServeHTTP(w, r)
+}
+
+//Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- devicesDesc
+	ch <- statusDesc
+	ch <- osVersionDesc
+	ch <- supervisorVersionDesc
+	ch <- lastSeenDesc
+	ch <- refreshesDesc
+	ch <- refreshErrorsDesc
+	ch <- refreshDurationDesc
+}
+
+//Collect implements prometheus.Collector. The fleet state is fetched from the
+//resin API when the cached state is older than the cache interval. The last
+//good state is served when fetching fails.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refreshIfStale()
+	up := 0.0
+	if e.up {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up)
+	ch <- prometheus.MustNewConstMetric(refreshesDesc, prometheus.CounterValue, e.refreshes)
+	ch <- prometheus.MustNewConstMetric(refreshErrorsDesc, prometheus.CounterValue, e.refreshErrors)
+	ch <- prometheus.MustNewConstMetric(refreshDurationDesc, prometheus.GaugeValue, e.duration.Seconds())
+	if e.state == nil {
+		return
+	}
+	for id, devs := range e.state.devices {
+		// the name is empty for applications missing from the list.
+		app := []string{strconv.FormatInt(id, 10), e.state.apps[id]}
+		f, _ := resingo.DevFleetHealth(resingo.NewDeviceSliceIterator(devs))
+		ch <- prometheus.MustNewConstMetric(devicesDesc, prometheus.GaugeValue, float64(f.Online), append(app, "online")...)
+		ch <- prometheus.MustNewConstMetric(devicesDesc, prometheus.GaugeValue, float64(f.Offline), append(app, "offline")...)
+		for k, v := range f.ByStatus {
+			ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, float64(v), append(app, k)...)
+		}
+		for k, v := range f.ByOSVersion {
+			ch <- prometheus.MustNewConstMetric(osVersionDesc, prometheus.GaugeValue, float64(v), append(app, k)...)
+		}
+		for k, v := range f.BySupervisorVersion {
+			ch <- prometheus.MustNewConstMetric(supervisorVersionDesc, prometheus.GaugeValue, float64(v), append(app, k)...)
+		}
+		for _, d := range devs {
+			if !d.LastConnectivityEvent.Valid {
+				continue
+			}
+			since := e.now().Sub(d.LastConnectivityEvent.Time).Seconds()
+			ch <- prometheus.MustNewConstMetric(lastSeenDesc, prometheus.GaugeValue, since, append(app, d.UUID, d.Name)...)
+		}
+	}
+}
+
+func (e *Exporter) refreshIfStale() {
+	if e.fetched.IsZero() || e.now().Sub(e.fetched) >= e.interval {
+		e.refresh()
+	}
+}
+
+func (e *Exporter) refresh() {
+	start := e.now()
+	e.refreshes++
+	s, err := fetchFleet(e.ctx)
+	e.duration = e.now().Sub(start)
+
+	// failed refreshes wait for the interval too, so an unavailable API is
+	// not hammered by every scrape.
+	e.fetched = e.now()
+	if err != nil {
+		e.refreshErrors++
+		e.up = false
+		return
+	}
+	e.up = true
+	e.state = s
+}
+
+func fetchFleet(ctx *resingo.Context) (*fleetState, error) {
+	apps, err := resingo.AppGetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devs, err := resingo.DevGetAll(ctx)
+	if err != nil && err != resingo.ErrDeviceNotFound {
+		return nil, err
+	}
+	s := &fleetState{
+		apps:    make(map[int64]string),
+		devices: make(map[int64][]*resingo.Device),
+	}
+	for _, a := range apps {
+		s.apps[a.ID] = a.Name
+		s.devices[a.ID] = nil
+	}
+	for _, d := range devs {
+		id := d.Application.ID
+		s.devices[id] = append(s.devices[id], d)
+	}
+	return s, nil
+}
@@ -0,0 +1,114 @@
+package exporter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gernest/resingo"
+)
+
+func scrape(t *testing.T, e *Exporter) string {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	b, _ := ioutil.ReadAll(w.Body)
+	return string(b)
+}
+
+func TestExporter(t *testing.T) {
+	seen := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	hits := 0
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/application":
+			fmt.Fprint(w, `{"d":[{"id":1,"app_name":"fleet"},{"id":2,"app_name":"empty"},{"id":3,"app_name":"fleet"},{"id":4,"app_name":"9"}]}`)
+		case "/v1/device":
+			fmt.Fprintf(w, `{"d":[
+{"uuid":"aaa","name":"one","application":{"__id":1},"is_online":true,"status":"Idle","os_version":"Resin OS 2.0.6+rev3","supervisor_version":"6.0.1","last_connectivity_event":%q},
+{"uuid":"bbb","name":"two","application":{"__id":1},"is_online":false,"status":"Idle","os_version":"2.0.6+rev3"},
+{"uuid":"ccc","name":"three","application":{"__id":9},"is_online":true,"status":"Downloading"},
+{"uuid":"ddd","name":"four","application":{"__id":3},"is_online":true,"status":"Idle","last_connectivity_event":%q}
+]}`, seen.Format(time.RFC3339), seen.Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	tr := NewTransport(nil)
+	ctx := &resingo.Context{
+		Client: &http.Client{Transport: tr},
+		Config: &resingo.Config{ResinEndpoint: ts.URL},
+	}
+	now := seen.Add(90 * time.Second)
+	e := New(ctx, &Options{Transport: tr, CacheInterval: time.Minute})
+	e.now = func() time.Time { return now }
+
+	out := scrape(t, e)
+	sample := []string{
+		`resin_up 1`,
+		`resin_devices{application="fleet",application_id="1",state="online"} 1`,
+		`resin_devices{application="fleet",application_id="1",state="offline"} 1`,
+		`resin_devices{application="fleet",application_id="3",state="online"} 1`,
+		`resin_devices{application="",application_id="9",state="online"} 1`,
+		`resin_devices{application="9",application_id="4",state="online"} 0`,
+		`resin_devices{application="empty",application_id="2",state="offline"} 0`,
+		`resin_devices_by_status{application="fleet",application_id="1",status="Idle"} 2`,
+		`resin_devices_by_status{application="fleet",application_id="3",status="Idle"} 1`,
+		`resin_devices_by_os_version{application="fleet",application_id="1",version="2.0.6+rev3"} 2`,
+		`resin_devices_by_supervisor_version{application="fleet",application_id="1",version="unknown"} 1`,
+		`resin_device_last_connectivity_seconds{application="fleet",application_id="1",name="one",uuid="aaa"} 90`,
+		`resin_device_last_connectivity_seconds{application="fleet",application_id="3",name="four",uuid="ddd"} 90`,
+		`resin_exporter_refreshes_total 1`,
+		`resin_api_request_duration_seconds_count{code="200",method="GET",resource="device"} 1`,
+		`resin_api_request_duration_seconds_count{code="200",method="GET",resource="application"} 1`,
+	}
+	for _, v := range sample {
+		if !strings.Contains(out, v) {
+			t.Errorf("expected %s in\n%s", v, out)
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 api requests got %d", hits)
+	}
+
+	scrape(t, e)
+	if hits != 2 {
+		t.Errorf("expected the cached state to be served got %d api requests", hits)
+	}
+
+	fail = true
+	now = now.Add(time.Minute)
+	out = scrape(t, e)
+	if hits != 3 {
+		t.Errorf("expected a refresh got %d api requests", hits)
+	}
+	sample = []string{
+		`resin_up 0`,
+		`resin_exporter_refresh_errors_total 1`,
+		`resin_devices{application="fleet",application_id="1",state="online"} 1`,
+		`resin_api_request_errors_total{method="GET",resource="application"} 1`,
+		`resin_api_request_duration_seconds_count{code="503",method="GET",resource="application"} 1`,
+	}
+	for _, v := range sample {
+		if !strings.Contains(out, v) {
+			t.Errorf("expected %s in\n%s", v, out)
+		}
+	}
+	scrape(t, e)
+	if hits != 3 {
+		t.Errorf("expected failed refreshes to wait for the interval got %d api requests", hits)
+	}
+}
@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gernest/resingo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Transport is a http.RoundTripper that records the latency and errors of the
+//requests to the resin API. It is a prometheus collector.
+type Transport struct {
+	// Base is the transport that sends the requests, http.DefaultTransport
+	// is used when nil.
+	Base http.RoundTripper
+
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+}
+
+//NewTransport returns a Transport sending the requests with base.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base: base,
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests to the resin API.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "resource", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "request_errors_total",
+			Help:      "Number of requests to the resin API that failed or got a 4xx or 5xx response.",
+		}, []string{"method", "resource"}),
+	}
+}
+
+//RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resource := resingo.APIResource(req.URL)
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	t.latency.WithLabelValues(req.Method, resource, code).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= 400 {
+		t.errors.WithLabelValues(req.Method, resource).Inc()
+	}
+	return resp, err
+}
+
+//Describe implements prometheus.Collector.
+func (t *Transport) Describe(ch chan<- *prometheus.Desc) {
+	t.latency.Describe(ch)
+	t.errors.Describe(ch)
+}
+
+//Collect implements prometheus.Collector.
+func (t *Transport) Collect(ch chan<- prometheus.Metric) {
+	t.latency.Collect(ch)
+	t.errors.Collect(ch)
+}
@@ -0,0 +1,787 @@
+package resingo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+//ErrNoBootPartition is returned when an image doesn't have a FAT boot
+//partition.
+var ErrNoBootPartition = errors.New("resingo: image has no FAT boot partition")
+
+//ErrBootPartitionFull is returned when there is no space left in the boot
+//partition of an image.
+var ErrBootPartitionFull = errors.New("resingo: boot partition is full")
+
+//ImageInjectConfig writes cfg as config.json to the boot partition of the
+//resin OS image at path, the files in cfg.Files are carried in config.json.
+//The image is modified in place, no root privileges or loop devices are
+//needed.
+//
+// The image must be a raw disk image, ErrCompressedImage is returned for
+// archives like the ones downloaded for compressed device types.
+//
+//	cfg, err := DevProvisioningConfig(ctx, "fleet", nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	err = ImageInjectConfig("resin.img", cfg)
+func ImageInjectConfig(image string, cfg *DeviceConfig) error {
+	b, err := cfg.JSON()
+	if err != nil {
+		return err
+	}
+	return ImageWriteFile(image, "config.json", b)
+}
+
+//ImageWriteFile replaces the content of the file with the given name in the
+//boot partition of the image at path. Only existing files can be replaced, an
+//error satisfying os.IsNotExist is returned for missing files. The content is
+//limited to MaxImageFileSize bytes.
+func ImageWriteFile(image, name string, data []byte) error {
+	if len(data) > MaxImageFileSize {
+		return fmt.Errorf("resingo: %s is larger than %d bytes", name, MaxImageFileSize)
+	}
+	fs, err := openBootFS(image)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fs.dev.Close()
+	}()
+	err = fs.replaceFile(name, data)
+	if err != nil {
+		return err
+	}
+	return fs.dev.Close()
+}
+
+//ImageReadFile returns the content of the file with the given name in the boot
+//partition of the image at path.
+func ImageReadFile(image, name string) ([]byte, error) {
+	fs, err := openBootFS(image)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = fs.dev.Close()
+	}()
+	return fs.readFile(name)
+}
+
+//MaxImageFileSize is the maximum size of the files written by ImageWriteFile.
+const MaxImageFileSize = 1 << 20
+
+// size of the sectors of partition tables.
+const sectorSize = 512
+
+const (
+	fatAttrVolume = 0x08
+	fatAttrDir    = 0x10
+	fatAttrLFN    = 0x0F
+	fatEntrySize  = 32
+)
+
+type fatDevice interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+}
+
+// fatFS is a minimal FAT12/16/32 implementation which is just enough to read
+// existing files and replace their content, files and directories are never
+// created. The file allocation table is kept in memory and written to all
+// copies after every change.
+type fatFS struct {
+	dev       fatDevice
+	off       int64
+	typ       int
+	bps       int64
+	spc       int64
+	rsvd      int64
+	nfats     int64
+	fatSize   int64
+	rootEnts  int64
+	rootClus  uint32
+	fsInfo    int64
+	firstData int64
+	clusters  uint32
+	fat       []byte
+}
+
+func openBootFS(image string) (*fatFS, error) {
+	f, err := os.OpenFile(image, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if isCompressed(f) {
+		_ = f.Close()
+		return nil, ErrCompressedImage
+	}
+	off, err := findBootPartition(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	fs, err := newFatFS(f, off)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return fs, nil
+}
+
+// magic numbers of the archive formats resin OS images are shipped in.
+var compressedMagic = [][]byte{
+	[]byte("PK\x03\x04"),             // zip
+	{0x1F, 0x8B},                     // gzip
+	{0xFD, '7', 'z', 'X', 'Z', 0x00}, // xz
+	[]byte("BZh"),                    // bzip2
+	{0x28, 0xB5, 0x2F, 0xFD},         // zstd
+}
+
+// reports whether r starts with the magic number of an archive.
+func isCompressed(r io.ReaderAt) bool {
+	b := make([]byte, 6)
+	n, _ := r.ReadAt(b, 0)
+	for _, m := range compressedMagic {
+		if n >= len(m) && bytes.Equal(b[:len(m)], m) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the offset of the first FAT partition of the disk image. Both MBR
+// and GPT partition tables are supported, a disk without partition table that
+// holds a FAT file system is also accepted.
+func findBootPartition(r io.ReaderAt) (int64, error) {
+	mbr := make([]byte, sectorSize)
+	_, err := r.ReadAt(mbr, 0)
+	if err != nil {
+		return 0, err
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xAA {
+		return 0, ErrNoBootPartition
+	}
+	var starts []int64
+	for i := 0; i < 4; i++ {
+		e := mbr[446+i*16 : 446+(i+1)*16]
+		lba := int64(binary.LittleEndian.Uint32(e[8:]))
+		switch e[4] {
+		case 0x00, 0x05, 0x0F:
+			// empty and extended partitions
+		case 0xEE:
+			s, err := gptPartitions(r)
+			if err != nil {
+				return 0, err
+			}
+			starts = append(starts, s...)
+		default:
+			starts = append(starts, lba*sectorSize)
+		}
+	}
+	sec := make([]byte, sectorSize)
+	for _, s := range starts {
+		_, err := r.ReadAt(sec, s)
+		if err != nil {
+			continue
+		}
+		if isFATBootSector(sec) {
+			return s, nil
+		}
+	}
+	if isFATBootSector(mbr) {
+		return 0, nil
+	}
+	return 0, ErrNoBootPartition
+}
+
+func gptPartitions(r io.ReaderAt) ([]int64, error) {
+	h := make([]byte, sectorSize)
+	_, err := r.ReadAt(h, sectorSize)
+	if err != nil {
+		return nil, err
+	}
+	if string(h[:8]) != "EFI PART" {
+		return nil, errors.New("resingo: bad GPT header")
+	}
+	lba := int64(binary.LittleEndian.Uint64(h[72:]))
+	n := int64(binary.LittleEndian.Uint32(h[80:]))
+	size := int64(binary.LittleEndian.Uint32(h[84:]))
+	if size < 128 || n > 1024 {
+		return nil, errors.New("resingo: bad GPT header")
+	}
+	b := make([]byte, n*size)
+	_, err = r.ReadAt(b, lba*sectorSize)
+	if err != nil {
+		return nil, err
+	}
+	var rst []int64
+	for i := int64(0); i < n; i++ {
+		e := b[i*size : (i+1)*size]
+		if isZero(e[:16]) {
+			continue
+		}
+		rst = append(rst, int64(binary.LittleEndian.Uint64(e[32:]))*sectorSize)
+	}
+	return rst, nil
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isFATBootSector(b []byte) bool {
+	if b[0] != 0xEB && b[0] != 0xE9 {
+		return false
+	}
+	if b[510] != 0x55 || b[511] != 0xAA {
+		return false
+	}
+	switch binary.LittleEndian.Uint16(b[11:]) {
+	case 512, 1024, 2048, 4096:
+	default:
+		return false
+	}
+	spc := b[13]
+	if spc == 0 || spc&(spc-1) != 0 {
+		return false
+	}
+	if binary.LittleEndian.Uint16(b[14:]) == 0 || b[16] == 0 {
+		return false
+	}
+	return binary.LittleEndian.Uint16(b[22:]) != 0 || binary.LittleEndian.Uint32(b[36:]) != 0
+}
+
+func newFatFS(dev fatDevice, off int64) (*fatFS, error) {
+	b := make([]byte, sectorSize)
+	_, err := dev.ReadAt(b, off)
+	if err != nil {
+		return nil, err
+	}
+	if !isFATBootSector(b) {
+		return nil, ErrNoBootPartition
+	}
+	fs := &fatFS{
+		dev:      dev,
+		off:      off,
+		bps:      int64(binary.LittleEndian.Uint16(b[11:])),
+		spc:      int64(b[13]),
+		rsvd:     int64(binary.LittleEndian.Uint16(b[14:])),
+		nfats:    int64(b[16]),
+		rootEnts: int64(binary.LittleEndian.Uint16(b[17:])),
+		fatSize:  int64(binary.LittleEndian.Uint16(b[22:])),
+	}
+	total := int64(binary.LittleEndian.Uint16(b[19:]))
+	if total == 0 {
+		total = int64(binary.LittleEndian.Uint32(b[32:]))
+	}
+	if fs.fatSize == 0 {
+		fs.fatSize = int64(binary.LittleEndian.Uint32(b[36:]))
+	}
+	rootSectors := (fs.rootEnts*fatEntrySize + fs.bps - 1) / fs.bps
+	fs.firstData = fs.rsvd + fs.nfats*fs.fatSize + rootSectors
+	if total <= fs.firstData {
+		return nil, errors.New("resingo: bad FAT boot sector")
+	}
+	fs.clusters = uint32((total - fs.firstData) / fs.spc)
+	switch {
+	case fs.clusters < 4085:
+		fs.typ = 12
+	case fs.clusters < 65525:
+		fs.typ = 16
+	default:
+		fs.typ = 32
+		fs.rootClus = binary.LittleEndian.Uint32(b[44:])
+		fs.fsInfo = int64(binary.LittleEndian.Uint16(b[48:]))
+	}
+
+	// some formatters make the table too small for the last clusters, like
+	// linux those clusters are not used.
+	if max := fs.fatSize * fs.bps * 8 / int64(fs.typ); int64(fs.clusters)+2 > max {
+		if max <= 2 {
+			return nil, errors.New("resingo: bad FAT boot sector")
+		}
+		fs.clusters = uint32(max - 2)
+	}
+	fs.fat = make([]byte, fs.fatSize*fs.bps)
+	_, err = dev.ReadAt(fs.fat, off+fs.rsvd*fs.bps)
+	if err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *fatFS) get(n uint32) uint32 {
+	switch fs.typ {
+	case 12:
+		v := uint32(binary.LittleEndian.Uint16(fs.fat[n+n/2:]))
+		if n&1 == 1 {
+			return v >> 4
+		}
+		return v & 0xFFF
+	case 16:
+		return uint32(binary.LittleEndian.Uint16(fs.fat[n*2:]))
+	}
+	return binary.LittleEndian.Uint32(fs.fat[n*4:]) & 0x0FFFFFFF
+}
+
+func (fs *fatFS) set(n, v uint32) {
+	switch fs.typ {
+	case 12:
+		i := n + n/2
+		w := binary.LittleEndian.Uint16(fs.fat[i:])
+		if n&1 == 1 {
+			w = w&0x000F | uint16(v)<<4
+		} else {
+			w = w&0xF000 | uint16(v)&0xFFF
+		}
+		binary.LittleEndian.PutUint16(fs.fat[i:], w)
+	case 16:
+		binary.LittleEndian.PutUint16(fs.fat[n*2:], uint16(v))
+	default:
+		old := binary.LittleEndian.Uint32(fs.fat[n*4:])
+		binary.LittleEndian.PutUint32(fs.fat[n*4:], old&0xF0000000|v&0x0FFFFFFF)
+	}
+}
+
+func (fs *fatFS) eoc() uint32 {
+	switch fs.typ {
+	case 12:
+		return 0xFFF
+	case 16:
+		return 0xFFFF
+	}
+	return 0x0FFFFFFF
+}
+
+func (fs *fatFS) isEOC(v uint32) bool {
+	return v >= fs.eoc()&^7
+}
+
+func (fs *fatFS) chain(start uint32) ([]uint32, error) {
+	var rst []uint32
+	for c := start; ; {
+		if c < 2 || c >= fs.clusters+2 || uint32(len(rst)) > fs.clusters {
+			return nil, errors.New("resingo: corrupt FAT cluster chain")
+		}
+		rst = append(rst, c)
+		next := fs.get(c)
+		if fs.isEOC(next) {
+			return rst, nil
+		}
+		c = next
+	}
+}
+
+// allocates a chain of n free clusters.
+func (fs *fatFS) alloc(n int) ([]uint32, error) {
+	var rst []uint32
+	for c := uint32(2); c < fs.clusters+2 && len(rst) < n; c++ {
+		if fs.get(c) == 0 {
+			rst = append(rst, c)
+		}
+	}
+	if len(rst) < n {
+		return nil, ErrBootPartitionFull
+	}
+	for i, c := range rst {
+		if i == len(rst)-1 {
+			fs.set(c, fs.eoc())
+		} else {
+			fs.set(c, rst[i+1])
+		}
+	}
+	return rst, nil
+}
+
+func (fs *fatFS) free(chain []uint32) {
+	for _, c := range chain {
+		fs.set(c, 0)
+	}
+}
+
+// writes the in memory table to all copies of the file allocation table.
+func (fs *fatFS) flush() error {
+	for i := int64(0); i < fs.nfats; i++ {
+		_, err := fs.dev.WriteAt(fs.fat, fs.off+(fs.rsvd+i*fs.fatSize)*fs.bps)
+		if err != nil {
+			return err
+		}
+	}
+	if fs.typ == 32 && fs.fsInfo != 0 && fs.fsInfo != 0xFFFF {
+		// the free cluster count and hint are no longer valid.
+		unknown := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+		_, err := fs.dev.WriteAt(unknown, fs.off+fs.fsInfo*fs.bps+488)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *fatFS) clusterSize() int64 {
+	return fs.spc * fs.bps
+}
+
+func (fs *fatFS) clusterOffset(c uint32) int64 {
+	return fs.off + (fs.firstData+int64(c-2)*fs.spc)*fs.bps
+}
+
+func (fs *fatFS) readChain(chain []uint32) ([]byte, error) {
+	size := fs.clusterSize()
+	b := make([]byte, int64(len(chain))*size)
+	for i, c := range chain {
+		_, err := fs.dev.ReadAt(b[int64(i)*size:int64(i+1)*size], fs.clusterOffset(c))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// writes data to the clusters of chain, the last cluster is padded with zeros.
+func (fs *fatFS) writeChain(chain []uint32, data []byte) error {
+	size := fs.clusterSize()
+	buf := make([]byte, size)
+	for i, c := range chain {
+		for j := range buf {
+			buf[j] = 0
+		}
+		start := int64(i) * size
+		if start < int64(len(data)) {
+			copy(buf, data[start:])
+		}
+		_, err := fs.dev.WriteAt(buf, fs.clusterOffset(c))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fatDir is a directory loaded in memory. The chain is nil for the fixed root
+// directory of FAT12 and FAT16.
+type fatDir struct {
+	chain []uint32
+	data  []byte
+}
+
+type fatEntry struct {
+	name    string
+	index   int
+	attr    byte
+	cluster uint32
+	size    uint32
+}
+
+func (fs *fatFS) rootOffset() int64 {
+	return fs.off + (fs.rsvd+fs.nfats*fs.fatSize)*fs.bps
+}
+
+// reads the directory starting at cluster, cluster 0 is the root directory.
+func (fs *fatFS) readDir(cluster uint32) (*fatDir, error) {
+	d := &fatDir{}
+	if cluster == 0 && fs.typ != 32 {
+		d.data = make([]byte, fs.rootEnts*fatEntrySize)
+		_, err := fs.dev.ReadAt(d.data, fs.rootOffset())
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	if cluster == 0 {
+		cluster = fs.rootClus
+	}
+	chain, err := fs.chain(cluster)
+	if err != nil {
+		return nil, err
+	}
+	d.chain = chain
+	d.data, err = fs.readChain(chain)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *fatDir) entries() []fatEntry {
+	var rst []fatEntry
+	var lfn []uint16
+	var lfnSum byte
+	lfnNext := 0
+	for i := 0; i < len(d.data)/fatEntrySize; i++ {
+		e := d.data[i*fatEntrySize : (i+1)*fatEntrySize]
+		if e[0] == 0x00 {
+			break
+		}
+		if e[0] == 0xE5 {
+			lfnNext = 0
+			continue
+		}
+		if e[11]&0x3F == fatAttrLFN {
+			seq := int(e[0] & 0x1F)
+			if e[0]&0x40 != 0 {
+				lfn = make([]uint16, seq*13)
+				lfnSum = e[13]
+				lfnNext = seq
+			}
+			if seq == 0 || seq != lfnNext || e[13] != lfnSum {
+				lfnNext = 0
+				continue
+			}
+			p := lfn[(seq-1)*13:]
+			for j := 0; j < 5; j++ {
+				p[j] = binary.LittleEndian.Uint16(e[1+j*2:])
+			}
+			for j := 0; j < 6; j++ {
+				p[5+j] = binary.LittleEndian.Uint16(e[14+j*2:])
+			}
+			for j := 0; j < 2; j++ {
+				p[11+j] = binary.LittleEndian.Uint16(e[28+j*2:])
+			}
+			lfnNext--
+			continue
+		}
+		if e[11]&fatAttrVolume != 0 {
+			lfnNext = 0
+			continue
+		}
+		var short [11]byte
+		copy(short[:], e[:11])
+		name := fatShortDisplay(e)
+		if lfn != nil && lfnNext == 0 && lfnSum == fatShortSum(short) {
+			name = fatLongName(lfn)
+		}
+		lfn = nil
+		lfnNext = 0
+		if name == "." || name == ".." {
+			continue
+		}
+		rst = append(rst, fatEntry{
+			name:  name,
+			index: i,
+			attr:  e[11],
+			cluster: uint32(binary.LittleEndian.Uint16(e[20:]))<<16 |
+				uint32(binary.LittleEndian.Uint16(e[26:])),
+			size: binary.LittleEndian.Uint32(e[28:]),
+		})
+	}
+	return rst
+}
+
+func (d *fatDir) lookup(name string) *fatEntry {
+	for _, e := range d.entries() {
+		if strings.EqualFold(e.name, name) {
+			return &e
+		}
+	}
+	return nil
+}
+
+func fatLongName(lfn []uint16) string {
+	for i, c := range lfn {
+		if c == 0 {
+			lfn = lfn[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(lfn))
+}
+
+func fatShortDisplay(e []byte) string {
+	base := strings.TrimRight(string(e[:8]), " ")
+	ext := strings.TrimRight(string(e[8:11]), " ")
+	if len(base) > 0 && base[0] == 0x05 {
+		base = "\xE5" + base[1:]
+	}
+	if e[12]&0x08 != 0 {
+		base = strings.ToLower(base)
+	}
+	if e[12]&0x10 != 0 {
+		ext = strings.ToLower(ext)
+	}
+	if ext == "" {
+		return base
+	}
+	return base + "." + ext
+}
+
+func fatShortSum(short [11]byte) byte {
+	var sum byte
+	for _, c := range short {
+		sum = (sum&1)<<7 + sum>>1 + c
+	}
+	return sum
+}
+
+func fatSetCluster(e []byte, c uint32) {
+	binary.LittleEndian.PutUint16(e[20:], uint16(c>>16))
+	binary.LittleEndian.PutUint16(e[26:], uint16(c))
+}
+
+// sets the modification and access time of the entry.
+func fatSetTime(e []byte, t time.Time) {
+	date := uint16(t.Year()-1980)<<9 | uint16(t.Month())<<5 | uint16(t.Day())
+	tm := uint16(t.Hour())<<11 | uint16(t.Minute())<<5 | uint16(t.Second()/2)
+	binary.LittleEndian.PutUint16(e[18:], date)
+	binary.LittleEndian.PutUint16(e[22:], tm)
+	binary.LittleEndian.PutUint16(e[24:], date)
+}
+
+func splitFATPath(name string) ([]string, error) {
+	var parts []string
+	for _, p := range strings.Split(name, "/") {
+		if p == "" || p == "." {
+			continue
+		}
+		if p == ".." {
+			return nil, fmt.Errorf("resingo: bad path %s", name)
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("resingo: bad path %s", name)
+	}
+	return parts, nil
+}
+
+// returns the directory which holds the file with the given path.
+func (fs *fatFS) parentDir(parts []string) (*fatDir, error) {
+	d, err := fs.readDir(0)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range parts[:len(parts)-1] {
+		e := d.lookup(p)
+		if e == nil {
+			return nil, &os.PathError{Op: "open", Path: strings.Join(parts[:i+1], "/"), Err: os.ErrNotExist}
+		}
+		if e.attr&fatAttrDir == 0 {
+			return nil, fmt.Errorf("resingo: %s is not a directory", p)
+		}
+		d, err = fs.readDir(e.cluster)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// returns the directory entry of the file with the given path.
+func (fs *fatFS) lookupFile(name string) (*fatDir, *fatEntry, error) {
+	parts, err := splitFATPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := fs.parentDir(parts)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := d.lookup(parts[len(parts)-1])
+	if e == nil {
+		return nil, nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if e.attr&fatAttrDir != 0 {
+		return nil, nil, fmt.Errorf("resingo: %s is a directory", name)
+	}
+	return d, e, nil
+}
+
+// returns the offset of the i'th entry of the directory on the device.
+func (fs *fatFS) entryOffset(d *fatDir, i int) int64 {
+	off := int64(i) * fatEntrySize
+	if d.chain == nil {
+		return fs.rootOffset() + off
+	}
+	size := fs.clusterSize()
+	return fs.clusterOffset(d.chain[off/size]) + off%size
+}
+
+// replaces the content of an existing file. The cluster chain of the file is
+// reused, it is extended with free clusters or the clusters that are no
+// longer needed are released.
+func (fs *fatFS) replaceFile(name string, data []byte) error {
+	d, e, err := fs.lookupFile(name)
+	if err != nil {
+		return err
+	}
+	var chain []uint32
+	if e.cluster != 0 {
+		chain, err = fs.chain(e.cluster)
+		if err != nil {
+			return err
+		}
+	}
+	size := fs.clusterSize()
+	need := int((int64(len(data)) + size - 1) / size)
+	switch {
+	case need > len(chain):
+		more, err := fs.alloc(need - len(chain))
+		if err != nil {
+			return err
+		}
+		if len(chain) > 0 {
+			fs.set(chain[len(chain)-1], more[0])
+		}
+		chain = append(chain, more...)
+	case need < len(chain):
+		fs.free(chain[need:])
+		if need > 0 {
+			fs.set(chain[need-1], fs.eoc())
+		}
+		chain = chain[:need]
+	}
+	err = fs.writeChain(chain, data)
+	if err != nil {
+		return err
+	}
+	var first uint32
+	if len(chain) > 0 {
+		first = chain[0]
+	}
+	b := d.data[e.index*fatEntrySize : (e.index+1)*fatEntrySize]
+	fatSetCluster(b, first)
+	fatSetTime(b, time.Now())
+	binary.LittleEndian.PutUint32(b[28:], uint32(len(data)))
+	_, err = fs.dev.WriteAt(b, fs.entryOffset(d, e.index))
+	if err != nil {
+		return err
+	}
+	return fs.flush()
+}
+
+func (fs *fatFS) readFile(name string) ([]byte, error) {
+	_, e, err := fs.lookupFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.cluster == 0 {
+		return []byte{}, nil
+	}
+	chain, err := fs.chain(e.cluster)
+	if err != nil {
+		return nil, err
+	}
+	b, err := fs.readChain(chain)
+	if err != nil {
+		return nil, err
+	}
+	if int64(e.size) > int64(len(b)) {
+		return nil, errors.New("resingo: corrupt FAT directory entry")
+	}
+	return b[:e.size], nil
+}
@@ -0,0 +1,350 @@
+package resingo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tools from dosfstools and mtools used to make and check the test images.
+var fatTools = []string{"mkfs.vfat", "fsck.vfat", "mmd", "mcopy", "mtype"}
+
+func lookFATTools(t *testing.T) {
+	for _, v := range fatTools {
+		_, err := exec.LookPath(v)
+		if err != nil {
+			t.Skipf("%s is not installed", v)
+		}
+	}
+}
+
+func runFATTool(t *testing.T, name string, args ...string) []byte {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "MTOOLS_SKIP_CHECK=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s %s: %v\n%s%s", name, strings.Join(args, " "), err, out, stderr.Bytes())
+	}
+	return out
+}
+
+// fatImage is a disk image holding a file system made by mkfs.vfat.
+type fatImage struct {
+	path string
+	fs   string
+	off  int64
+}
+
+// makes a FAT file system of the given type with mkfs.vfat and copies files to
+// it with mtools. The file system is put in an MBR partition when mbr is true.
+func mkfsImage(t *testing.T, dir string, typ int, mbr bool, files map[string]string) *fatImage {
+	lookFATTools(t)
+	img := &fatImage{
+		path: filepath.Join(dir, fmt.Sprintf("fat%d.img", typ)),
+		fs:   filepath.Join(dir, fmt.Sprintf("fat%d.fs", typ)),
+	}
+	args := []string{"-F", strconv.Itoa(typ)}
+	size := "40960"
+	switch typ {
+	case 12:
+		size = "4096"
+	case 32:
+		args = append(args, "-s", "1")
+	}
+	runFATTool(t, "mkfs.vfat", append(args, "-C", img.fs, size)...)
+
+	var names []string
+	dirs := make(map[string]bool)
+	for name := range files {
+		names = append(names, name)
+		for d := path.Dir(name); d != "."; d = path.Dir(d) {
+			dirs[d] = true
+		}
+	}
+	var mkdirs []string
+	for d := range dirs {
+		mkdirs = append(mkdirs, d)
+	}
+	sort.Strings(mkdirs)
+	for _, d := range mkdirs {
+		runFATTool(t, "mmd", "-i", img.fs, "::/"+d)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		src := filepath.Join(dir, fmt.Sprintf("src-%d", i))
+		err := ioutil.WriteFile(src, []byte(files[name]), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runFATTool(t, "mcopy", "-i", img.fs, src, "::/"+name)
+	}
+	if !mbr {
+		img.path = img.fs
+		return img
+	}
+	b, err := ioutil.ReadFile(img.fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.off = 2048 * sectorSize
+	mbrSector := make([]byte, sectorSize)
+	e := mbrSector[446:]
+	e[4] = 0x0C
+	binary.LittleEndian.PutUint32(e[8:], 2048)
+	binary.LittleEndian.PutUint32(e[12:], uint32(len(b)/sectorSize))
+	mbrSector[510], mbrSector[511] = 0x55, 0xAA
+	disk := make([]byte, img.off+int64(len(b)))
+	copy(disk, mbrSector)
+	copy(disk[img.off:], b)
+	err = ioutil.WriteFile(img.path, disk, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// copies the file system out of the partition so the tools can read it.
+func (img *fatImage) extract(t *testing.T) string {
+	if img.off == 0 {
+		return img.path
+	}
+	b, err := ioutil.ReadFile(img.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(img.fs, b[img.off:], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img.fs
+}
+
+// checks the file system with fsck.vfat and the content of files with mtype.
+func (img *fatImage) check(t *testing.T, files map[string]string) {
+	fs := img.extract(t)
+	runFATTool(t, "fsck.vfat", "-n", fs)
+	for name, data := range files {
+		b := runFATTool(t, "mtype", "-i", fs, "::/"+name)
+		if string(b) != data {
+			t.Errorf("%s: expected %d bytes got %d", name, len(data), len(b))
+		}
+	}
+}
+
+func TestImageFiles(t *testing.T) {
+	sample := []struct {
+		typ int
+		mbr bool
+	}{
+		{12, false},
+		{16, true},
+		{32, true},
+	}
+	for _, v := range sample {
+		t.Run(fmt.Sprintf("FAT%d", v.typ), func(ts *testing.T) {
+			dir, err := ioutil.TempDir("", "resingo")
+			if err != nil {
+				ts.Fatal(err)
+			}
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			files := map[string]string{
+				"config.json":                       `{"applicationName":"fleet"}`,
+				"empty":                             "",
+				"large.bin":                         strings.Repeat("0123456789", 5000),
+				"system-connections/resin-sample":   "[connection]\nid=resin-sample\n",
+				"splash/nested/resin-logo.png.orig": "png",
+			}
+			img := mkfsImage(ts, dir, v.typ, v.mbr, files)
+			for name, data := range files {
+				b, err := ImageReadFile(img.path, name)
+				if err != nil {
+					ts.Fatalf("reading %s: %v", name, err)
+				}
+				if string(b) != data {
+					ts.Errorf("%s: expected %d bytes got %d", name, len(data), len(b))
+				}
+			}
+			fs, err := openBootFS(img.path)
+			if err != nil {
+				ts.Fatal(err)
+			}
+			used := usedClusters(fs)
+			_ = fs.dev.Close()
+
+			// the files grow, shrink, become empty and stop being empty.
+			changed := map[string]string{
+				"CONFIG.JSON":                       strings.Repeat("{}", 6000),
+				"empty":                             "not empty",
+				"large.bin":                         "small",
+				"system-connections/resin-sample":   "",
+				"splash/nested/resin-logo.png.orig": strings.Repeat("p", MaxImageFileSize),
+			}
+			for name, data := range changed {
+				err = ImageWriteFile(img.path, name, []byte(data))
+				if err != nil {
+					ts.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			changed["config.json"] = changed["CONFIG.JSON"]
+			delete(changed, "CONFIG.JSON")
+			for name, data := range changed {
+				b, err := ImageReadFile(img.path, name)
+				if err != nil {
+					ts.Fatalf("reading %s: %v", name, err)
+				}
+				if string(b) != data {
+					ts.Errorf("%s: expected %d bytes got %d", name, len(data), len(b))
+				}
+			}
+			img.check(ts, changed)
+
+			// restoring the files must release the clusters of the old content.
+			for name, data := range files {
+				err = ImageWriteFile(img.path, name, []byte(data))
+				if err != nil {
+					ts.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			img.check(ts, files)
+			fs, err = openBootFS(img.path)
+			if err != nil {
+				ts.Fatal(err)
+			}
+			if n := usedClusters(fs); n != used {
+				ts.Errorf("expected %d used clusters got %d", used, n)
+			}
+			_ = fs.dev.Close()
+
+			for _, name := range []string{"missing.txt", "system-connections/resin-wifi", "network/resin-wifi"} {
+				err = ImageWriteFile(img.path, name, []byte("x"))
+				if !os.IsNotExist(err) {
+					ts.Errorf("%s: expected not exist error got %v", name, err)
+				}
+				_, err = ImageReadFile(img.path, name)
+				if !os.IsNotExist(err) {
+					ts.Errorf("%s: expected not exist error got %v", name, err)
+				}
+			}
+			err = ImageWriteFile(img.path, "system-connections", []byte("x"))
+			if err == nil {
+				ts.Error("expected an error writing a directory")
+			}
+		})
+	}
+}
+
+func usedClusters(fs *fatFS) int {
+	n := 0
+	for c := uint32(2); c < fs.clusters+2; c++ {
+		if fs.get(c) != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestImageInjectConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resingo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// many files make the root directory span several sectors.
+	files := map[string]string{"config.json": "{}"}
+	for i := 0; i < 40; i++ {
+		files[fmt.Sprintf("overlay-%02d.dtbo", i)] = "dtb"
+	}
+	img := mkfsImage(t, dir, 16, true, files)
+	cfg := &DeviceConfig{
+		ApplicationName: "fleet",
+		UUID:            "abc",
+		Files: map[string]string{
+			"system-connections/resin-wifi": "[connection]\nid=resin-wifi\n",
+		},
+	}
+	err = ImageInjectConfig(img.path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ImageReadFile(img.path, "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &DeviceConfig{}
+	err = json.Unmarshal(b, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UUID != cfg.UUID {
+		t.Errorf("expected %s got %s", cfg.UUID, got.UUID)
+	}
+	if got.Files["system-connections/resin-wifi"] != cfg.Files["system-connections/resin-wifi"] {
+		t.Errorf("unexpected network config %v", got.Files)
+	}
+	files["config.json"] = string(b)
+	img.check(t, files)
+}
+
+func TestImageErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resingo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	cfg := &DeviceConfig{UUID: "abc"}
+	_, err = ImageReadFile(filepath.Join(dir, "missing.img"), "config.json")
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error got %v", err)
+	}
+	sample := []struct {
+		name string
+		data []byte
+	}{
+		{"resin.img.gz", []byte{0x1F, 0x8B, 0x08, 0x00}},
+		{"resin.img.xz", []byte{0xFD, '7', 'z', 'X', 'Z', 0x00, 0x00}},
+		{"resin.zip", []byte("PK\x03\x04")},
+	}
+	for _, v := range sample {
+		p := filepath.Join(dir, v.name)
+		err = ioutil.WriteFile(p, v.data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ImageInjectConfig(p, cfg)
+		if err != ErrCompressedImage {
+			t.Errorf("%s: expected %v got %v", v.name, ErrCompressedImage, err)
+		}
+	}
+	p := filepath.Join(dir, "disk.img")
+	err = ioutil.WriteFile(p, make([]byte, 4*sectorSize), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ImageInjectConfig(p, cfg)
+	if err != ErrNoBootPartition {
+		t.Errorf("expected %v got %v", ErrNoBootPartition, err)
+	}
+	err = ImageWriteFile(p, "config.json", make([]byte, MaxImageFileSize+1))
+	if err == nil {
+		t.Error("expected an error for a file above the size limit")
+	}
+}
@@ -0,0 +1,65 @@
+package resingo
+
+import (
+	"net/url"
+	"strings"
+)
+
+//Filter restricts the resources returned by list functions like DevGetAll and
+//AppGetAll. It is an OData $filter expression on the listed resource, when
+//several filters are given the resources must match all of them.
+//
+//	// all devices of the application 12 in berlin
+//	devs, err := DevGetAll(ctx,
+//		Filter("application eq 12"),
+//		DevFilterTag("site", "berlin"),
+//	)
+type Filter string
+
+//DevFilterTag returns a filter matching the devices that have the tag key set
+//to value. When value is empty, devices with the tag are matched regardless of
+//the value.
+func DevFilterTag(key, value string) Filter {
+	return tagFilter("device_tag", key, value)
+}
+
+//AppFilterTag returns a filter matching the applications that have the tag key
+//set to value. When value is empty, applications with the tag are matched
+//regardless of the value.
+func AppFilterTag(key, value string) Filter {
+	return tagFilter("application_tag", key, value)
+}
+
+func tagFilter(resource, key, value string) Filter {
+	e := "t/tag_key eq " + odataString(key)
+	if value != "" {
+		e += " and t/value eq " + odataString(value)
+	}
+	return Filter(resource + "/any(t:" + e + ")")
+}
+
+// quotes s as an OData string literal.
+func odataString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// returns the params of a request listing resources matching all filters, it
+// returns nil when there are no filters.
+func filterParams(filters []Filter) url.Values {
+	if len(filters) == 0 {
+		return nil
+	}
+	e := string(filters[0])
+	if len(filters) > 1 {
+		p := make([]string, len(filters))
+		for i, f := range filters {
+			p[i] = "(" + string(f) + ")"
+		}
+		e = strings.Join(p, " and ")
+	}
+	params := make(url.Values)
+
+	// Encode writes the filter as it is.
+	params.Set("filter", url.PathEscape(e))
+	return params
+}
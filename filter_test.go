@@ -0,0 +1,84 @@
+package resingo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilterParams(t *testing.T) {
+	sample := []struct {
+		filters []Filter
+		expect  string
+	}{
+		{nil, ""},
+		{[]Filter{"application eq 7"}, "$filter=application%20eq%207"},
+		{
+			[]Filter{DevFilterTag("site", "o'hare")},
+			"$filter=device_tag%2Fany%28t:t%2Ftag_key%20eq%20%27site%27%20and%20t%2Fvalue%20eq%20%27o%27%27hare%27%29",
+		},
+		{
+			[]Filter{"application eq 7", AppFilterTag("customer", "")},
+			"$filter=%28application%20eq%207%29%20and%20%28application_tag%2Fany%28t:t%2Ftag_key%20eq%20%27customer%27%29%29",
+		},
+	}
+	for _, v := range sample {
+		if got := Encode(filterParams(v.filters)); got != v.expect {
+			t.Errorf("expected %s got %s", v.expect, got)
+		}
+	}
+}
+
+func TestGetAllByTag(t *testing.T) {
+	var filters []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("$filter"))
+		switch r.URL.Path {
+		case "/v1/device":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": []*Device{{ID: 1}}})
+		case "/v1/application":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": []*Application{{ID: 2}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	ctx := &Context{
+		Client: &http.Client{},
+		Config: &Config{ResinEndpoint: ts.URL},
+	}
+	devs, err := DevGetAllByTag(ctx, "site", "berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 || devs[0].ID != 1 {
+		t.Errorf("expected the device got %v", devs)
+	}
+	apps, err := AppGetAllByTag(ctx, "customer", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].ID != 2 {
+		t.Errorf("expected the application got %v", apps)
+	}
+	it := DevIter(ctx, 7, 0, DevFilterTag("site", "berlin"))
+	for it.Next() {
+	}
+	if err = it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"device_tag/any(t:t/tag_key eq 'site' and t/value eq 'berlin')",
+		"application_tag/any(t:t/tag_key eq 'customer')",
+		"(application eq 7) and (device_tag/any(t:t/tag_key eq 'site' and t/value eq 'berlin'))",
+	}
+	if len(filters) != len(expect) {
+		t.Fatalf("expected %d requests got %v", len(expect), filters)
+	}
+	for i, v := range expect {
+		if filters[i] != v {
+			t.Errorf("%d: expected %s got %s", i, v, filters[i])
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package resingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//ErrBadCoordinates is returned for coordinates outside of the valid range.
+var ErrBadCoordinates = errors.New("resingo: bad coordinates")
+
+//ErrNoLocation is returned when a device has no coordinates.
+var ErrNoLocation = errors.New("resingo: device has no location")
+
+// mean radius of the earth in meters.
+const earthRadius = 6371008.8
+
+//GeoPoint is a point on earth in decimal degrees.
+type GeoPoint struct {
+	Lat float64
+	Lon float64
+}
+
+//NewGeoPoint returns a validated GeoPoint.
+func NewGeoPoint(lat, lon float64) (GeoPoint, error) {
+	p := GeoPoint{Lat: lat, Lon: lon}
+	return p, p.Validate()
+}
+
+//ParseGeoPoint parses the latitude and longitude strings as reported by
+//resin.
+func ParseGeoPoint(lat, lon string) (GeoPoint, error) {
+	lat, lon = strings.TrimSpace(lat), strings.TrimSpace(lon)
+	if lat == "" && lon == "" {
+		return GeoPoint{}, ErrNoLocation
+	}
+	la, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return GeoPoint{}, ErrBadCoordinates
+	}
+	lo, err := strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return GeoPoint{}, ErrBadCoordinates
+	}
+	return NewGeoPoint(la, lo)
+}
+
+//Validate returns ErrBadCoordinates if the point is not a valid location.
+func (p GeoPoint) Validate() error {
+	if math.IsNaN(p.Lat) || math.IsNaN(p.Lon) ||
+		p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return ErrBadCoordinates
+	}
+	return nil
+}
+
+func (p GeoPoint) String() string {
+	return fmt.Sprintf("%s,%s", formatCoord(p.Lat), formatCoord(p.Lon))
+}
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+//DistanceTo returns the great circle distance to q in meters.
+func (p GeoPoint) DistanceTo(q GeoPoint) float64 {
+	rad := math.Pi / 180
+	dLat := (q.Lat - p.Lat) * rad
+	dLon := (q.Lon - p.Lon) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(p.Lat*rad)*math.Cos(q.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+//BoundingBox is an area between two corners. A box with SouthWest.Lon greater
+//than NorthEast.Lon crosses the antimeridian.
+type BoundingBox struct {
+	SouthWest GeoPoint
+	NorthEast GeoPoint
+}
+
+//Contains returns true if p is inside the box, edges included.
+func (b BoundingBox) Contains(p GeoPoint) bool {
+	if p.Lat < b.SouthWest.Lat || p.Lat > b.NorthEast.Lat {
+		return false
+	}
+	if b.SouthWest.Lon <= b.NorthEast.Lon {
+		return p.Lon >= b.SouthWest.Lon && p.Lon <= b.NorthEast.Lon
+	}
+	return p.Lon >= b.SouthWest.Lon || p.Lon <= b.NorthEast.Lon
+}
+
+//GeoPoint returns the location of the device. The location set with
+//DevSetLocation is preferred over the location reported by the device.
+func (d *Device) GeoPoint() (GeoPoint, error) {
+	if d.CustomLatitude != "" || d.CustomLongitude != "" {
+		return ParseGeoPoint(d.CustomLatitude, d.CustomLongitude)
+	}
+	return ParseGeoPoint(d.Latitude, d.Longitude)
+}
+
+//DevSetLocation overrides the location of the device with the given id. The
+//location is a free text description like the city, it is not changed when
+//empty.
+func DevSetLocation(ctx *Context, id int64, p GeoPoint, location string) error {
+	err := p.Validate()
+	if err != nil {
+		return err
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(fmt.Sprintf("device(%d)", id))
+	data := make(map[string]interface{})
+	data["custom_latitude"] = formatCoord(p.Lat)
+	data["custom_longitude"] = formatCoord(p.Lon)
+	if location != "" {
+		data["location"] = location
+	}
+	body, err := marhsalReader(data)
+	if err != nil {
+		return err
+	}
+	b, err := doJSON(ctx, "PATCH", uri, h, nil, body)
+	if err != nil {
+		return err
+	}
+	if string(b) != "OK" {
+		return errors.New("bad response")
+	}
+	return nil
+}
+
+//DevWithinBox returns the devices inside the bounding box. Devices without a
+//valid location are skipped.
+func DevWithinBox(it DeviceIterator, box BoundingBox) ([]*Device, error) {
+	var rst []*Device
+	for it.Next() {
+		d := it.Device()
+		p, err := d.GeoPoint()
+		if err != nil {
+			continue
+		}
+		if box.Contains(p) {
+			rst = append(rst, d)
+		}
+	}
+	return rst, it.Err()
+}
+
+//DevWithinRadius returns the devices within radius meters of center, sorted
+//from the nearest. Devices without a valid location are skipped.
+//
+//	// devices within 10km of berlin
+//	it := DevIter(ctx, appID, 0)
+//	devs, err := DevWithinRadius(it, GeoPoint{Lat: 52.52, Lon: 13.405}, 10000)
+func DevWithinRadius(it DeviceIterator, center GeoPoint, radius float64) ([]*Device, error) {
+	var rst []*Device
+	dist := make(map[*Device]float64)
+	for it.Next() {
+		d := it.Device()
+		p, err := d.GeoPoint()
+		if err != nil {
+			continue
+		}
+		if m := center.DistanceTo(p); m <= radius {
+			dist[d] = m
+			rst = append(rst, d)
+		}
+	}
+	sort.SliceStable(rst, func(i, j int) bool {
+		return dist[rst[i]] < dist[rst[j]]
+	})
+	return rst, it.Err()
+}
+
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	Geometry struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+//DevToGeoJSON returns a GeoJSON feature collection with a point for every
+//device that has a location.
+func DevToGeoJSON(devs []*Device) ([]byte, error) {
+	features := []*geoJSONFeature{}
+	for _, d := range devs {
+		p, err := d.GeoPoint()
+		if err != nil {
+			continue
+		}
+		f := &geoJSONFeature{Type: "Feature"}
+		f.Geometry.Type = "Point"
+
+		// GeoJSON puts the longitude first.
+		f.Geometry.Coordinates = [2]float64{p.Lon, p.Lat}
+		f.Properties = map[string]interface{}{
+			"id":          d.ID,
+			"uuid":        d.UUID,
+			"name":        d.Name,
+			"device_type": d.Type,
+			"is_online":   d.IsOnline,
+			"status":      d.Status,
+			"location":    d.Location,
+		}
+		features = append(features, f)
+	}
+	return json.Marshal(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
@@ -0,0 +1,137 @@
+package resingo
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestGeoPoint(t *testing.T) {
+	sample := []struct {
+		lat, lon string
+		point    GeoPoint
+		err      error
+	}{
+		{"52.52", "13.405", GeoPoint{Lat: 52.52, Lon: 13.405}, nil},
+		{" -33.8688 ", "151.2093", GeoPoint{Lat: -33.8688, Lon: 151.2093}, nil},
+		{"", "", GeoPoint{}, ErrNoLocation},
+		{"91", "0", GeoPoint{}, ErrBadCoordinates},
+		{"0", "-180.5", GeoPoint{}, ErrBadCoordinates},
+		{"north", "0", GeoPoint{}, ErrBadCoordinates},
+		{"NaN", "0", GeoPoint{}, ErrBadCoordinates},
+	}
+	for _, v := range sample {
+		p, err := ParseGeoPoint(v.lat, v.lon)
+		if err != v.err {
+			t.Errorf("%s,%s: expected %v got %v", v.lat, v.lon, v.err, err)
+			continue
+		}
+		if err == nil && p != v.point {
+			t.Errorf("%s,%s: expected %v got %v", v.lat, v.lon, v.point, p)
+		}
+	}
+	d := &Device{Latitude: "52.52", Longitude: "13.405", CustomLatitude: "48.8566", CustomLongitude: "2.3522"}
+	p, err := d.GeoPoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Lat != 48.8566 {
+		t.Errorf("expected the custom location got %v", p)
+	}
+
+	// berlin to paris is about 878km.
+	berlin := GeoPoint{Lat: 52.52, Lon: 13.405}
+	if m := berlin.DistanceTo(p); math.Abs(m-878000) > 5000 {
+		t.Errorf("expected about 878km got %f", m)
+	}
+}
+
+func TestGeoQueries(t *testing.T) {
+	devs := []*Device{
+		{UUID: "berlin", Latitude: "52.52", Longitude: "13.405"},
+		{UUID: "potsdam", Latitude: "52.3906", Longitude: "13.0645"},
+		{UUID: "paris", Latitude: "48.8566", Longitude: "2.3522"},
+		{UUID: "fiji", Latitude: "-17.7134", Longitude: "178.065"},
+		{UUID: "samoa", Latitude: "-13.759", Longitude: "-172.1046"},
+		{UUID: "nowhere"},
+		{UUID: "broken", Latitude: "x", Longitude: "y"},
+	}
+	uuids := func(d []*Device) []string {
+		var rst []string
+		for _, v := range d {
+			rst = append(rst, v.UUID)
+		}
+		return rst
+	}
+	europe := BoundingBox{
+		SouthWest: GeoPoint{Lat: 35, Lon: -10},
+		NorthEast: GeoPoint{Lat: 60, Lon: 30},
+	}
+	rst, err := DevWithinBox(NewDeviceSliceIterator(devs), europe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uuids(rst); len(got) != 3 {
+		t.Errorf("expected 3 devices in europe got %v", got)
+	}
+	pacific := BoundingBox{
+		SouthWest: GeoPoint{Lat: -25, Lon: 170},
+		NorthEast: GeoPoint{Lat: -10, Lon: -170},
+	}
+	rst, err = DevWithinBox(NewDeviceSliceIterator(devs), pacific)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uuids(rst); len(got) != 2 || got[0] != "fiji" || got[1] != "samoa" {
+		t.Errorf("expected fiji and samoa got %v", got)
+	}
+	rst, err = DevWithinRadius(NewDeviceSliceIterator(devs), GeoPoint{Lat: 52.4, Lon: 13.1}, 50000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uuids(rst); len(got) != 2 || got[0] != "potsdam" || got[1] != "berlin" {
+		t.Errorf("expected potsdam then berlin got %v", got)
+	}
+}
+
+func TestDevToGeoJSON(t *testing.T) {
+	devs := []*Device{
+		{ID: 1, UUID: "berlin", Latitude: "52.52", Longitude: "13.405", IsOnline: true},
+		{ID: 2, UUID: "nowhere"},
+	}
+	b, err := DevToGeoJSON(devs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	err = json.Unmarshal(b, &fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+		t.Fatalf("unexpected feature collection %s", b)
+	}
+	f := fc.Features[0]
+	if f.Geometry.Type != "Point" || f.Geometry.Coordinates[0] != 13.405 || f.Geometry.Coordinates[1] != 52.52 {
+		t.Errorf("unexpected geometry %+v", f.Geometry)
+	}
+	if f.Properties["uuid"] != "berlin" || f.Properties["is_online"] != true {
+		t.Errorf("unexpected properties %v", f.Properties)
+	}
+	b, err = DevToGeoJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"features":[],"type":"FeatureCollection"}` {
+		t.Errorf("unexpected empty collection %s", b)
+	}
+}
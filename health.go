@@ -0,0 +1,168 @@
+package resingo
+
+import (
+	"strings"
+
+	"github.com/guregu/null"
+)
+
+//DeviceHealth is a snapshot of the health of a device.
+type DeviceHealth struct {
+	UUID                  string
+	Name                  string
+	DeviceType            string
+	IsOnline              bool
+	LastConnectivityEvent null.Time
+	Status                string
+
+	// DownloadProgress is the progress in percent of the application update
+	// being downloaded, it is null when there is no update.
+	DownloadProgress     null.Int
+	ProvisioningProgress null.Int
+	ProvisioningState    string
+
+	OSVersion         string
+	SupervisorVersion string
+
+	VPNAddress    string
+	PublicAddress string
+	LocalAddress  []string
+
+	// Usage in percent, memory and storage totals in MB and the CPU
+	// temperature in celsius. They are null when the device doesn't report
+	// them.
+	CPUUsage     null.Int
+	CPUTemp      null.Int
+	MemoryUsage  null.Int
+	MemoryTotal  null.Int
+	StorageUsage null.Int
+	StorageTotal null.Int
+
+	// Agent is the state reported by the supervisor, it is nil when the
+	// device is offline or the supervisor couldn't be reached, in which case
+	// AgentErr is set.
+	Agent    *AgentState
+	AgentErr error
+}
+
+//DevHealth returns a health snapshot of the device with the given uuid. When
+//the device is online the supervisor is asked for its state, which is more
+//recent than the state stored by the API.
+func DevHealth(ctx *Context, uuid string) (*DeviceHealth, error) {
+	dev, err := DevGetByUUID(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	h := deviceHealth(dev)
+	if !dev.IsOnline {
+		h.AgentErr = ErrDeviceOffline
+		return h, nil
+	}
+	s, err := AgentDeviceState(ctx, uuid)
+	if err != nil {
+		h.AgentErr = err
+		return h, nil
+	}
+	h.Agent = s
+	if s.Status != "" {
+		h.Status = s.Status
+	}
+	if s.DownloadProgress.Valid {
+		h.DownloadProgress = s.DownloadProgress
+	}
+	if s.OSVersion != "" {
+		h.OSVersion = s.OSVersion
+	}
+	if s.SupervisorVersion != "" {
+		h.SupervisorVersion = s.SupervisorVersion
+	}
+	if s.IPAddress != "" {
+		h.LocalAddress = strings.Fields(s.IPAddress)
+	}
+	return h, nil
+}
+
+func deviceHealth(dev *Device) *DeviceHealth {
+	return &DeviceHealth{
+		UUID:                  dev.UUID,
+		Name:                  dev.Name,
+		DeviceType:            dev.Type,
+		IsOnline:              dev.IsOnline,
+		LastConnectivityEvent: dev.LastConnectivityEvent,
+		Status:                dev.Status,
+		DownloadProgress:      dev.DownloadProgress,
+		ProvisioningProgress:  dev.ProvisioningProgress,
+		ProvisioningState:     dev.ProvisioningState,
+		OSVersion:             dev.OsVersion,
+		SupervisorVersion:     dev.SuprevisorVersion,
+		VPNAddress:            dev.VPNAddr,
+		PublicAddress:         dev.PublicAddr,
+		LocalAddress:          strings.Fields(dev.IP),
+		CPUUsage:              dev.CPUUsage,
+		CPUTemp:               dev.CPUTemp,
+		MemoryUsage:           dev.MemoryUsage,
+		MemoryTotal:           dev.MemoryTotal,
+		StorageUsage:          dev.StorageUsage,
+		StorageTotal:          dev.StorageTotal,
+	}
+}
+
+//FleetHealth is the aggregated health of many devices.
+type FleetHealth struct {
+	Total    int
+	Online   int
+	Offline  int
+	Updating int
+
+	ByStatus            map[string]int
+	ByOSVersion         map[string]int
+	BySupervisorVersion map[string]int
+	ByDeviceType        map[string]int
+}
+
+//DevFleetHealth counts the devices of the iterator per status, OS version,
+//supervisor version and device type. Versions are normalized, so "Resin OS
+//2.0.6+rev3" and "2.0.6+rev3" are counted together, missing values are counted
+//as unknown.
+//
+//	fleet, err := DevFleetHealth(DevIter(ctx, appID, 0))
+func DevFleetHealth(it DeviceIterator) (*FleetHealth, error) {
+	f := &FleetHealth{
+		ByStatus:            make(map[string]int),
+		ByOSVersion:         make(map[string]int),
+		BySupervisorVersion: make(map[string]int),
+		ByDeviceType:        make(map[string]int),
+	}
+	for it.Next() {
+		d := it.Device()
+		f.Total++
+		if d.IsOnline {
+			f.Online++
+		} else {
+			f.Offline++
+		}
+		if d.DownloadProgress.Valid {
+			f.Updating++
+		}
+		f.ByStatus[orUnknown(d.Status)]++
+		f.ByOSVersion[normalizeVersion(d.OsVersion)]++
+		f.BySupervisorVersion[normalizeVersion(d.SuprevisorVersion)]++
+		f.ByDeviceType[orUnknown(d.Type)]++
+	}
+	return f, it.Err()
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func normalizeVersion(s string) string {
+	v, err := ParseVersion(s)
+	if err != nil {
+		return orUnknown(s)
+	}
+	return v.String()
+}
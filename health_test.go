@@ -0,0 +1,121 @@
+package resingo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guregu/null"
+)
+
+func TestDevHealth(t *testing.T) {
+	devices := []*Device{
+		{
+			UUID: "aaa", Type: "raspberrypi3", IsOnline: true, Status: "Idle",
+			OsVersion: "Resin OS 2.0.0+rev1", SuprevisorVersion: "4.1.0",
+			IP: "192.168.1.2 10.0.0.2", VPNAddr: "10.2.0.1", PublicAddr: "1.2.3.4",
+			CPUTemp: null.IntFrom(48), MemoryTotal: null.IntFrom(1024),
+		},
+		{UUID: "bbb", Type: "raspberrypi3", IsOnline: false, Status: "Idle", IP: "192.168.1.3"},
+		{UUID: "ccc", Type: "raspberrypi3", IsOnline: true, Status: "Idle"},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/device":
+			var rst []*Device
+			for _, d := range devices {
+				if strings.Contains(r.URL.RawQuery, d.UUID) {
+					rst = append(rst, d)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": rst})
+		case "/supervisor/v1/device":
+			var req struct {
+				UUID   string `json:"uuid"`
+				Method string `json:"method"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.UUID != "aaa" || req.Method != "GET" {
+				http.Error(w, "timeout", http.StatusGatewayTimeout)
+				return
+			}
+			fmt.Fprint(w, `{"api_port":48484,"ip_address":"192.168.1.9","status":"Downloading","download_progress":42,"os_version":"Resin OS 2.0.6+rev3","supervisor_version":"6.0.1","update_pending":true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	ctx := &Context{
+		Client: &http.Client{Transport: &rewriteTransport{target: target}},
+		Config: &Config{ResinEndpoint: apiEndpoint},
+	}
+	h, err := DevHealth(ctx, "aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Agent == nil || !h.Agent.UpdatePending {
+		t.Fatalf("expected the supervisor state got %v", h.AgentErr)
+	}
+	if h.Status != "Downloading" || h.DownloadProgress.Int64 != 42 || h.OSVersion != "Resin OS 2.0.6+rev3" {
+		t.Errorf("expected the supervisor state to win got %+v", h)
+	}
+	if !reflect.DeepEqual(h.LocalAddress, []string{"192.168.1.9"}) || h.VPNAddress != "10.2.0.1" {
+		t.Errorf("unexpected addresses %v %s", h.LocalAddress, h.VPNAddress)
+	}
+	if h.CPUTemp.Int64 != 48 || h.MemoryTotal.Int64 != 1024 || h.CPUUsage.Valid {
+		t.Errorf("unexpected metrics %+v", h)
+	}
+	h, err = DevHealth(ctx, "bbb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.AgentErr != ErrDeviceOffline || h.Agent != nil {
+		t.Errorf("expected %v got %v", ErrDeviceOffline, h.AgentErr)
+	}
+	if !reflect.DeepEqual(h.LocalAddress, []string{"192.168.1.3"}) {
+		t.Errorf("unexpected addresses %v", h.LocalAddress)
+	}
+	h, err = DevHealth(ctx, "ccc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.AgentErr == nil || h.Status != "Idle" {
+		t.Errorf("expected the api state when the supervisor fails got %+v", h)
+	}
+}
+
+func TestDevFleetHealth(t *testing.T) {
+	devs := []*Device{
+		{Type: "raspberrypi3", IsOnline: true, Status: "Idle", OsVersion: "Resin OS 2.0.6+rev3", SuprevisorVersion: "6.0.1"},
+		{Type: "raspberrypi3", IsOnline: true, Status: "Downloading", OsVersion: "2.0.6+rev3", DownloadProgress: null.IntFrom(10)},
+		{Type: "raspberrypi2", IsOnline: false, Status: "Idle", OsVersion: "Resin OS 2.0.0+rev1 (prod)", SuprevisorVersion: "v6.0.1"},
+		{Type: "raspberrypi2"},
+	}
+	f, err := DevFleetHealth(NewDeviceSliceIterator(devs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Total != 4 || f.Online != 2 || f.Offline != 2 || f.Updating != 1 {
+		t.Errorf("unexpected counts %+v", f)
+	}
+	sample := []struct {
+		got    map[string]int
+		expect map[string]int
+	}{
+		{f.ByStatus, map[string]int{"Idle": 2, "Downloading": 1, "unknown": 1}},
+		{f.ByOSVersion, map[string]int{"2.0.6+rev3": 2, "2.0.0+rev1": 1, "unknown": 1}},
+		{f.BySupervisorVersion, map[string]int{"6.0.1": 2, "unknown": 2}},
+		{f.ByDeviceType, map[string]int{"raspberrypi3": 2, "raspberrypi2": 2}},
+	}
+	for _, v := range sample {
+		if !reflect.DeepEqual(v.got, v.expect) {
+			t.Errorf("expected %v got %v", v.expect, v.got)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package resingo
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+//RequestEvent describes a request to the resin API. It is passed to the Hooks
+//of the context. Credentials are replaced by Redacted in the URL, the header
+//and the error. The same event is passed to every hook called for a request,
+//so hooks can use it as a key to correlate them.
+type RequestEvent struct {
+	// Context is the context of the request, hooks can use it as the parent
+	// of the work they do for the request, see Context.Parent.
+	Context context.Context
+
+	Method string
+	URL    string
+
+	// Resource is the API resource the request is for, see APIResource.
+	Resource string
+	Header   http.Header
+
+	// Status is the status code of the response, it is 0 when no response
+	// was received.
+	Status int
+
+	// Duration is the time from sending the request until the response body
+	// is closed.
+	Duration      time.Duration
+	BytesSent     int64
+	BytesReceived int64
+	Err           error
+
+	req *http.Request
+}
+
+//SetHeader sets a header of the request, hooks can use it in OnRequest to
+//propagate the context of the request. It has no effect in the other hooks.
+func (ev *RequestEvent) SetHeader(key, value string) {
+	if ev.req != nil {
+		ev.req.Header.Set(key, value)
+	}
+}
+
+//Hooks are called for every request sent with the context. All of them are
+//optional. After OnRequest, exactly one of OnResponse and OnError is called.
+//
+//	ctx.Hooks = SlogHooks(slog.Default())
+type Hooks struct {
+	// OnRequest is called before the request is sent.
+	OnRequest func(*RequestEvent)
+
+	// OnResponse is called when the response body is closed, this includes
+	// responses with an error status.
+	OnResponse func(*RequestEvent)
+
+	// OnError is called when sending the request or reading the response
+	// body fails.
+	OnError func(*RequestEvent)
+}
+
+//MultiHooks returns hooks calling each of hooks in turn.
+func MultiHooks(hooks ...*Hooks) *Hooks {
+	call := func(get func(*Hooks) func(*RequestEvent)) func(*RequestEvent) {
+		return func(ev *RequestEvent) {
+			for _, h := range hooks {
+				if h != nil && get(h) != nil {
+					get(h)(ev)
+				}
+			}
+		}
+	}
+	return &Hooks{
+		OnRequest:  call(func(h *Hooks) func(*RequestEvent) { return h.OnRequest }),
+		OnResponse: call(func(h *Hooks) func(*RequestEvent) { return h.OnResponse }),
+		OnError:    call(func(h *Hooks) func(*RequestEvent) { return h.OnError }),
+	}
+}
+
+//SlogHooks returns hooks logging every request to logger. Requests are logged
+//at the debug level, responses with a 4xx or 5xx status at the warn level and
+//failed requests at the error level. Records are logged with the context of
+//the request, so handlers can link them to its trace.
+func SlogHooks(logger *slog.Logger) *Hooks {
+	attrs := func(ev *RequestEvent) []slog.Attr {
+		return []slog.Attr{
+			slog.String("method", ev.Method),
+			slog.String("url", ev.URL),
+			slog.Int("status", ev.Status),
+			slog.Duration("duration", ev.Duration),
+			slog.Int64("bytes_sent", ev.BytesSent),
+			slog.Int64("bytes_received", ev.BytesReceived),
+		}
+	}
+	return &Hooks{
+		OnResponse: func(ev *RequestEvent) {
+			level := slog.LevelDebug
+			if ev.Status >= 400 {
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(eventContext(ev), level, "resingo: request", attrs(ev)...)
+		},
+		OnError: func(ev *RequestEvent) {
+			a := append(attrs(ev), slog.String("error", ev.Err.Error()))
+			logger.LogAttrs(eventContext(ev), slog.LevelError, "resingo: request failed", a...)
+		},
+	}
+}
+
+func eventContext(ev *RequestEvent) context.Context {
+	if ev.Context == nil {
+		return context.Background()
+	}
+	return ev.Context
+}
+
+// sends req with the client of ctx and calls the hooks of ctx. All requests
+// to the resin API must be sent with it.
+func send(ctx *Context, req *http.Request) (*http.Response, error) {
+	if ctx.Config != nil && ctx.Config.debug() {
+		d, err := newDebugDump(ctx.Config, req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := sendHooked(ctx, req)
+		if err != nil {
+			d.writeError(err)
+			return nil, err
+		}
+		resp.Body = newDebugBody(d, resp)
+		return resp, nil
+	}
+	return sendHooked(ctx, req)
+}
+
+func sendHooked(ctx *Context, req *http.Request) (*http.Response, error) {
+	if ctx.Parent != nil && req.Context() == context.Background() {
+		req = req.WithContext(ctx.Parent)
+	}
+	h := ctx.Hooks
+	if h == nil {
+		return ctx.Client.Do(req)
+	}
+	ev := &RequestEvent{
+		Context:  req.Context(),
+		Method:   req.Method,
+		URL:      redactURL(req.URL),
+		Resource: APIResource(req.URL),
+		Header:   redactHeader(req.Header),
+	}
+	if req.ContentLength > 0 {
+		ev.BytesSent = req.ContentLength
+	}
+	if h.OnRequest != nil {
+		ev.req = req
+		h.OnRequest(ev)
+		ev.req = nil
+	}
+	start := time.Now()
+	resp, err := ctx.Client.Do(req)
+	if err != nil {
+		ev.Duration = time.Since(start)
+		ev.Err = redactError(err)
+		if h.OnError != nil {
+			h.OnError(ev)
+		}
+		return nil, err
+	}
+	ev.Status = resp.StatusCode
+	resp.Body = &hookedBody{ReadCloser: resp.Body, hooks: h, ev: ev, start: start}
+	return resp, nil
+}
+
+// counts the bytes read from a response body and calls the hooks when it is
+// closed.
+type hookedBody struct {
+	io.ReadCloser
+	hooks *Hooks
+	ev    *RequestEvent
+	start time.Time
+	err   error
+	once  sync.Once
+}
+
+func (b *hookedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.ev.BytesReceived += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *hookedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.ev.Duration = time.Since(b.start)
+		if b.err != nil {
+			b.ev.Err = b.err
+			if b.hooks.OnError != nil {
+				b.hooks.OnError(b.ev)
+			}
+			return
+		}
+		if b.hooks.OnResponse != nil {
+			b.hooks.OnResponse(b.ev)
+		}
+	})
+	return err
+}
+
+// errors returned by the http client quote the url of the request.
+func redactError(err error) error {
+	e, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	u, perr := url.Parse(e.URL)
+	if perr != nil {
+		return err
+	}
+	return &url.Error{Op: e.Op, URL: redactURL(u), Err: e.Err}
+}
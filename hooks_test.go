@@ -0,0 +1,221 @@
+package resingo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type recordedEvent struct {
+	hook string
+	ev   RequestEvent
+}
+
+func recordHooks(rec *[]recordedEvent) *Hooks {
+	record := func(hook string) func(*RequestEvent) {
+		return func(ev *RequestEvent) {
+			*rec = append(*rec, recordedEvent{hook: hook, ev: *ev})
+		}
+	}
+	return &Hooks{
+		OnRequest:  record("request"),
+		OnResponse: record("response"),
+		OnError:    record("error"),
+	}
+}
+
+func TestHooks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login_":
+			fmt.Fprint(w, "secret-session-token")
+		case "/v1/device":
+			fmt.Fprint(w, `{"d":[{"id":1,"uuid":"aaa"}]}`)
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	var rec []recordedEvent
+	ctx := &Context{
+		Client: &http.Client{Transport: &rewriteTransport{target: target}},
+		Config: &Config{
+			ResinEndpoint: apiEndpoint,
+			Username:      "me",
+			Password:      "secret-password",
+			AuthToken:     "secret-auth-token",
+		},
+		Hooks: recordHooks(&rec),
+	}
+	tok, err := Authenticate(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != "secret-session-token" {
+		t.Errorf("expected the session token got %s", tok)
+	}
+	if _, err = DevGetAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = AppGetAll(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	sample := []struct {
+		hook     string
+		method   string
+		resource string
+		status   int
+		sent     int64
+		received int64
+	}{
+		{"request", "POST", "login_", 0, 36, 0},
+		{"response", "POST", "login_", 200, 36, 20},
+		{"request", "GET", "device", 0, 0, 0},
+		{"response", "GET", "device", 200, 0, 29},
+		{"request", "GET", "application", 0, 0, 0},
+		{"response", "GET", "application", 500, 0, 5},
+	}
+	if len(rec) != len(sample) {
+		t.Fatalf("expected %d events got %d", len(sample), len(rec))
+	}
+	for i, v := range sample {
+		e := rec[i]
+		if e.hook != v.hook || e.ev.Method != v.method || e.ev.Resource != v.resource ||
+			e.ev.Status != v.status || e.ev.BytesSent != v.sent || e.ev.BytesReceived != v.received {
+			t.Errorf("%d: expected %+v got %s %+v", i, v, e.hook, e.ev)
+		}
+		if v.hook == "response" && e.ev.Duration <= 0 {
+			t.Errorf("%d: expected a duration", i)
+		}
+	}
+	auth := rec[3].ev.Header.Get("Authorization")
+	if auth != "Bearer "+Redacted {
+		t.Errorf("expected the authorization to be redacted got %s", auth)
+	}
+	if rec[2].ev.URL != apiEndpoint+"/v1/device" {
+		t.Errorf("expected the url before the transport rewrote it got %s", rec[2].ev.URL)
+	}
+
+	rec = nil
+	ts.Close()
+	ctx.Config.ResinEndpoint = ts.URL
+	if _, err = DevGetAll(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(rec) != 2 || rec[1].hook != "error" || rec[1].ev.Err == nil {
+		t.Errorf("expected the error hook got %+v", rec)
+	}
+}
+
+func TestMultiHooks(t *testing.T) {
+	var a, b []recordedEvent
+	h := MultiHooks(recordHooks(&a), nil, &Hooks{}, recordHooks(&b))
+	ev := &RequestEvent{Method: "GET"}
+	h.OnRequest(ev)
+	h.OnResponse(ev)
+	h.OnError(ev)
+	if len(a) != 3 || len(b) != 3 || a[2].hook != "error" {
+		t.Errorf("expected all hooks to be called got %v %v", a, b)
+	}
+}
+
+func TestSlogHooks(t *testing.T) {
+	var buf bytes.Buffer
+	h := SlogHooks(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	sample := []struct {
+		ev     *RequestEvent
+		failed bool
+		expect string
+	}{
+		{&RequestEvent{Method: "GET", URL: "https://api.resin.io/v1/device", Status: 200, BytesReceived: 10}, false, "level=DEBUG msg=\"resingo: request\" method=GET url=https://api.resin.io/v1/device status=200 duration=0s bytes_sent=0 bytes_received=10"},
+		{&RequestEvent{Method: "GET", Status: 404}, false, "level=WARN"},
+		{&RequestEvent{Method: "GET", Err: fmt.Errorf("refused")}, true, "level=ERROR msg=\"resingo: request failed\""},
+	}
+	for _, v := range sample {
+		buf.Reset()
+		if v.failed {
+			h.OnError(v.ev)
+		} else {
+			h.OnResponse(v.ev)
+		}
+		if !strings.Contains(buf.String(), v.expect) {
+			t.Errorf("expected %s in %s", v.expect, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "error=refused") {
+		t.Errorf("expected the error to be logged got %s", buf.String())
+	}
+
+	type key struct{}
+	c := context.WithValue(context.Background(), key{}, "span-1")
+	var got []interface{}
+	h = SlogHooks(slog.New(&contextHandler{Handler: slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), key: key{}, got: &got}))
+	h.OnResponse(&RequestEvent{Context: c, Status: 200})
+	h.OnError(&RequestEvent{Context: c, Err: fmt.Errorf("refused")})
+	if len(got) != 2 || got[0] != "span-1" || got[1] != "span-1" {
+		t.Errorf("expected the records to be logged with the request context got %v", got)
+	}
+}
+
+// records the value of key in the context of every handled record.
+type contextHandler struct {
+	slog.Handler
+	key interface{}
+	got *[]interface{}
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	*h.got = append(*h.got, ctx.Value(h.key))
+	return h.Handler.Handle(ctx, r)
+}
+
+func TestHooksContext(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Trace")
+		fmt.Fprint(w, `{"d":[]}`)
+	}))
+	defer ts.Close()
+	type key struct{}
+	parent := context.WithValue(context.Background(), key{}, "trace-1")
+	var seen []interface{}
+	record := func(ev *RequestEvent) {
+		seen = append(seen, ev.Context.Value(key{}))
+		ev.SetHeader("X-Trace", "late")
+	}
+	ctx := &Context{
+		Client: &http.Client{},
+		Config: &Config{ResinEndpoint: ts.URL},
+		Hooks: &Hooks{
+			OnRequest: func(ev *RequestEvent) {
+				seen = append(seen, ev.Context.Value(key{}))
+				ev.SetHeader("X-Trace", fmt.Sprint(ev.Context.Value(key{})))
+			},
+			OnResponse: record,
+		},
+		Parent: parent,
+	}
+	if _, err := DevGetAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != "trace-1" || seen[1] != "trace-1" {
+		t.Errorf("expected the parent context in every hook got %v", seen)
+	}
+	if got != "trace-1" {
+		t.Errorf("expected the header set in OnRequest got %q", got)
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.Parent = c
+	if _, err := DevGetAll(ctx); err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("expected the request to be canceled got %v", err)
+	}
+}
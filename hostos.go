@@ -0,0 +1,306 @@
+package resingo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultActionsEndpoint is the endpoint of the resin actions service.
+const DefaultActionsEndpoint = "https://actions.resin.io"
+
+// host OS update statuses reported by the actions service.
+const (
+	OSUpdateIdle        = "idle"
+	OSUpdateTriggered   = "triggered"
+	OSUpdateConfiguring = "configuring"
+	OSUpdateInProgress  = "in_progress"
+	OSUpdateDone        = "done"
+	OSUpdateError       = "error"
+)
+
+//ErrDeviceOffline is returned when an action needs the device to be online.
+var ErrDeviceOffline = errors.New("resingo: device is offline")
+
+//ErrOSVersionNotNewer is returned when updating the host OS of a device to a
+//version which is not newer than the running version.
+var ErrOSVersionNotNewer = errors.New("resingo: target os version is not newer than the running version")
+
+//ErrUnknownOSVersion is returned when the OS version is not available for the
+//device type.
+var ErrUnknownOSVersion = errors.New("resingo: unknown os version")
+
+//ErrOSUpdateTimeout is returned by DevOSUpdateWait when the update didn't
+//finish in time.
+var ErrOSUpdateTimeout = errors.New("resingo: timeout waiting for os update")
+
+//OSVersions are the resin OS versions available for a device type, sorted
+//from the newest to the oldest.
+type OSVersions struct {
+	Versions []string `json:"versions"`
+	Latest   string   `json:"latest"`
+}
+
+//Has returns true if version is one of the available versions.
+func (o *OSVersions) Has(version string) bool {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false
+	}
+	for _, s := range o.Versions {
+		a, err := ParseVersion(s)
+		if err != nil {
+			continue
+		}
+		if a.Compare(v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//OSGetVersions returns the resin OS versions available for the device type.
+func OSGetVersions(ctx *Context, typ DeviceType) (*OSVersions, error) {
+	reg, err := DevTypeGetRegistry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slug, err := reg.Resolve(string(typ))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ConfigGet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ImageMakerURL == "" {
+		return nil, errors.New("resingo: missing image maker url")
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := fmt.Sprintf("%s/api/v1/image/%s/versions", strings.TrimSuffix(cfg.ImageMakerURL, "/"), slug)
+	b, err := doJSON(ctx, "GET", uri, h, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	rst := &OSVersions{}
+	err = json.Unmarshal(b, rst)
+	if err != nil {
+		return nil, err
+	}
+	SortVersions(rst.Versions)
+	if rst.Latest == "" && len(rst.Versions) > 0 {
+		rst.Latest = rst.Versions[0]
+	}
+	return rst, nil
+}
+
+//OSUpdateStatus is the status of a host OS update.
+type OSUpdateStatus struct {
+	Status     string `json:"status"`
+	Parameters struct {
+		TargetVersion string `json:"target_version"`
+	} `json:"parameters"`
+	Error string `json:"error"`
+	Fatal bool   `json:"fatal"`
+}
+
+//Finished returns true if the update is done or has failed.
+func (s *OSUpdateStatus) Finished() bool {
+	return s.Status == OSUpdateDone || s.Status == OSUpdateError
+}
+
+func (c *Config) actionsEndpoint() string {
+	if c.ActionsEndpoint != "" {
+		return strings.TrimSuffix(c.ActionsEndpoint, "/")
+	}
+	return DefaultActionsEndpoint
+}
+
+func osUpdateURI(ctx *Context, uuid string) string {
+	return fmt.Sprintf("%s/v1/devices/%s/resinhup", ctx.Config.actionsEndpoint(), uuid)
+}
+
+//DevOSUpdate starts a host OS update of the device with the given uuid to the
+//given version. The device must be online and the version must be newer than
+//the running version.
+//
+// The update runs on the device, use DevOSUpdateWait to wait for it to finish.
+func DevOSUpdate(ctx *Context, uuid, version string) (*OSUpdateStatus, error) {
+	dev, err := DevGetByUUID(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if !dev.IsOnline {
+		return nil, ErrDeviceOffline
+	}
+	target, err := ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	current, err := ParseVersion(dev.OsVersion)
+	if err != nil {
+		return nil, err
+	}
+	if target.Compare(current) <= 0 {
+		return nil, ErrOSVersionNotNewer
+	}
+	versions, err := OSGetVersions(ctx, DeviceType(dev.Type))
+	if err != nil {
+		return nil, err
+	}
+	if !versions.Has(version) {
+		return nil, ErrUnknownOSVersion
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	data := map[string]interface{}{
+		"parameters": map[string]interface{}{
+			"target_version": version,
+		},
+	}
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "POST", osUpdateURI(ctx, uuid), h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	rst := &OSUpdateStatus{}
+	err = json.Unmarshal(b, rst)
+	if err != nil {
+		return nil, err
+	}
+	return rst, nil
+}
+
+//DevOSUpdateStatus returns the status of the host OS update of the device with
+//the given uuid.
+func DevOSUpdateStatus(ctx *Context, uuid string) (*OSUpdateStatus, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	b, err := doJSON(ctx, "GET", osUpdateURI(ctx, uuid), h, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	rst := &OSUpdateStatus{}
+	err = json.Unmarshal(b, rst)
+	if err != nil {
+		return nil, err
+	}
+	return rst, nil
+}
+
+//OSUpdateWaitOptions configures how DevOSUpdateWait polls the update status.
+type OSUpdateWaitOptions struct {
+	// Interval between polls, defaults to 10 seconds.
+	Interval time.Duration
+
+	// Timeout defaults to 30 minutes.
+	Timeout time.Duration
+
+	// Progress is called with every polled status.
+	Progress func(uuid string, status *OSUpdateStatus)
+}
+
+//DevOSUpdateWait polls the status of the host OS update of the device with the
+//given uuid until the update is done or has failed. An error is returned when
+//the update failed. Waiting stops with the error of ctx.Parent when it is done.
+func DevOSUpdateWait(ctx *Context, uuid string, opts *OSUpdateWaitOptions) (*OSUpdateStatus, error) {
+	if opts == nil {
+		opts = &OSUpdateWaitOptions{}
+	}
+	interval := opts.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Minute
+	}
+	parent := ctx.Parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	deadline := time.Now().Add(timeout)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		st, err := DevOSUpdateStatus(ctx, uuid)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(uuid, st)
+		}
+		switch st.Status {
+		case OSUpdateDone:
+			return st, nil
+		case OSUpdateError:
+			return st, fmt.Errorf("resingo: os update of %s failed: %s", uuid, st.Error)
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return st, ErrOSUpdateTimeout
+		}
+		select {
+		case <-parent.Done():
+			return st, parent.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+//OSUpdateResult is the result of updating the host OS of one device of a
+//batch.
+type OSUpdateResult struct {
+	UUID   string
+	Status *OSUpdateStatus
+	Err    error
+}
+
+//OSUpdateBatchOptions configures DevOSUpdateAll.
+type OSUpdateBatchOptions struct {
+	// Concurrency is the number of devices updated at the same time, defaults
+	// to 4.
+	Concurrency int
+
+	// Wait waits for every update to finish, using WaitOptions.
+	Wait        bool
+	WaitOptions *OSUpdateWaitOptions
+}
+
+//DevOSUpdateAll updates the host OS of all devices with the given uuids to the
+//given version. The results are in the same order as uuids, a failure of one
+//device doesn't stop the update of the other devices.
+func DevOSUpdateAll(ctx *Context, uuids []string, version string, opts *OSUpdateBatchOptions) []*OSUpdateResult {
+	if opts == nil {
+		opts = &OSUpdateBatchOptions{}
+	}
+	n := opts.Concurrency
+	if n <= 0 {
+		n = 4
+	}
+	rst := make([]*OSUpdateResult, len(uuids))
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, uuid string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r := &OSUpdateResult{UUID: uuid}
+			r.Status, r.Err = DevOSUpdate(ctx, uuid, version)
+			if r.Err == nil && opts.Wait {
+				r.Status, r.Err = DevOSUpdateWait(ctx, uuid, opts.WaitOptions)
+			}
+			rst[i] = r
+		}(i, uuid)
+	}
+	wg.Wait()
+	return rst
+}
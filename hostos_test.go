@@ -0,0 +1,191 @@
+package resingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHUP is a fake resin API, image maker and actions service.
+type fakeHUP struct {
+	mu      sync.Mutex
+	devices map[string]*Device
+	polls   map[string]int
+	fail    map[string]bool
+}
+
+func (f *fakeHUP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/device":
+		var rst []*Device
+		for uuid, d := range f.devices {
+			if strings.Contains(r.URL.RawQuery, uuid) {
+				rst = append(rst, d)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": rst})
+	case r.URL.Path == "/api/v1/image/raspberrypi3/versions":
+		fmt.Fprint(w, `{"versions":["2.0.0+rev1","2.0.6+rev3","2.0.6+rev1"],"latest":"2.0.6+rev3"}`)
+	case strings.HasPrefix(r.URL.Path, "/v1/devices/"):
+		uuid := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/devices/"), "/resinhup")
+		status := OSUpdateTriggered
+		if r.Method == "GET" {
+			f.polls[uuid]++
+			switch {
+			case f.polls[uuid] < 3:
+				status = OSUpdateInProgress
+			case f.fail[uuid]:
+				status = OSUpdateError
+			default:
+				status = OSUpdateDone
+			}
+		}
+		fmt.Fprintf(w, `{"status":%q,"parameters":{"target_version":"2.0.6+rev3"},"error":"no space left"}`, status)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestOSUpdate(t *testing.T) {
+	fake := &fakeHUP{
+		devices: map[string]*Device{
+			"aaa": {UUID: "aaa", Type: "raspberrypi3", IsOnline: true, OsVersion: "Resin OS 2.0.0+rev1"},
+			"bbb": {UUID: "bbb", Type: "raspberrypi3", IsOnline: true, OsVersion: "Resin OS 2.0.6+rev1"},
+			"ccc": {UUID: "ccc", Type: "raspberrypi3", IsOnline: false, OsVersion: "Resin OS 2.0.0+rev1"},
+			"ddd": {UUID: "ddd", Type: "raspberrypi3", IsOnline: true, OsVersion: "Resin OS 2.0.6+rev3"},
+		},
+		polls: make(map[string]int),
+		fail:  map[string]bool{"bbb": true},
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	ctx := &Context{
+		Client: &http.Client{},
+		Config: &Config{
+			ResinEndpoint:   ts.URL,
+			ActionsEndpoint: ts.URL,
+		},
+		ConfigCache: &ConfigCache{
+			cfg: &ResinConfig{
+				ImageMakerURL: ts.URL,
+				DeviceTypes:   []DeviceTypeInfo{{Slug: "raspberrypi3", Name: "Raspberry Pi 3"}},
+			},
+			fetched: time.Now(),
+		},
+	}
+	t.Run("Versions", func(ts *testing.T) {
+		v, err := OSGetVersions(ctx, RaspberryPi3)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if v.Versions[0] != "2.0.6+rev3" || v.Latest != "2.0.6+rev3" {
+			ts.Errorf("expected 2.0.6+rev3 first got %v", v.Versions)
+		}
+		if !v.Has("Resin OS 2.0.6+rev1") || v.Has("2.0.7+rev1") {
+			ts.Error("unexpected available versions")
+		}
+	})
+	t.Run("Update", func(ts *testing.T) {
+		st, err := DevOSUpdate(ctx, "aaa", "2.0.6+rev3")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if st.Status != OSUpdateTriggered {
+			ts.Errorf("expected %s got %s", OSUpdateTriggered, st.Status)
+		}
+		var seen []string
+		st, err = DevOSUpdateWait(ctx, "aaa", &OSUpdateWaitOptions{
+			Interval: time.Millisecond,
+			Progress: func(uuid string, s *OSUpdateStatus) {
+				seen = append(seen, s.Status)
+			},
+		})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if !st.Finished() || len(seen) != 3 {
+			ts.Errorf("expected to poll until done got %v", seen)
+		}
+	})
+	t.Run("Rejected", func(ts *testing.T) {
+		sample := []struct {
+			uuid, version string
+			err           error
+		}{
+			{"ccc", "2.0.6+rev3", ErrDeviceOffline},
+			{"ddd", "2.0.6+rev3", ErrOSVersionNotNewer},
+			{"ddd", "2.0.0+rev1", ErrOSVersionNotNewer},
+			{"aaa", "2.0.7+rev1", ErrUnknownOSVersion},
+		}
+		for _, v := range sample {
+			_, err := DevOSUpdate(ctx, v.uuid, v.version)
+			if err != v.err {
+				ts.Errorf("%s: expected %v got %v", v.uuid, v.err, err)
+			}
+		}
+	})
+	t.Run("Batch", func(ts *testing.T) {
+		fake.mu.Lock()
+		fake.polls = make(map[string]int)
+		fake.mu.Unlock()
+		rst := DevOSUpdateAll(ctx, []string{"aaa", "bbb", "ccc"}, "2.0.6+rev3", &OSUpdateBatchOptions{
+			Wait:        true,
+			WaitOptions: &OSUpdateWaitOptions{Interval: time.Millisecond},
+		})
+		if len(rst) != 3 {
+			ts.Fatalf("expected 3 results got %d", len(rst))
+		}
+		if rst[0].UUID != "aaa" || rst[0].Err != nil || rst[0].Status.Status != OSUpdateDone {
+			ts.Errorf("expected aaa to be updated got %+v", rst[0])
+		}
+		if rst[1].Err == nil || rst[1].Status.Status != OSUpdateError {
+			ts.Errorf("expected bbb to fail got %+v", rst[1])
+		}
+		if rst[2].Err != ErrDeviceOffline {
+			ts.Errorf("expected %v got %v", ErrDeviceOffline, rst[2].Err)
+		}
+	})
+	t.Run("Timeout", func(ts *testing.T) {
+		fake.mu.Lock()
+		fake.polls = make(map[string]int)
+		fake.mu.Unlock()
+		_, err := DevOSUpdateWait(ctx, "aaa", &OSUpdateWaitOptions{
+			Interval: 10 * time.Millisecond,
+			Timeout:  15 * time.Millisecond,
+		})
+		if err != ErrOSUpdateTimeout {
+			ts.Errorf("expected %v got %v", ErrOSUpdateTimeout, err)
+		}
+	})
+	t.Run("Canceled", func(ts *testing.T) {
+		fake.mu.Lock()
+		fake.polls = make(map[string]int)
+		fake.mu.Unlock()
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		canceled := *ctx
+		canceled.Parent = c
+		start := time.Now()
+		st, err := DevOSUpdateWait(&canceled, "aaa", &OSUpdateWaitOptions{
+			Interval: time.Hour,
+			Timeout:  2 * time.Hour,
+			Progress: func(uuid string, s *OSUpdateStatus) {
+				cancel()
+			},
+		})
+		if err != context.Canceled || st == nil {
+			ts.Errorf("expected %v with the last status got %v %v", context.Canceled, st, err)
+		}
+		if time.Since(start) > time.Minute {
+			ts.Error("expected the wait to stop when canceled")
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package resingo
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//ErrChecksumMismatch is returned by ImageDownload when the checksum of the
+//downloaded image doesn't match the expected checksum.
+var ErrChecksumMismatch = errors.New("resingo: image checksum mismatch")
+
+//ErrCompressedImage is returned when the image is an archive instead of a raw
+//disk image.
+var ErrCompressedImage = errors.New("resingo: compressed image, a raw disk image is needed")
+
+//ImageProgress reports the progress of an image download. Total is -1 when
+//the size of the image is unknown.
+type ImageProgress struct {
+	Written int64
+	Total   int64
+}
+
+//ImageDownloadOptions configures ImageDownload.
+type ImageDownloadOptions struct {
+	// Path is the file the image is written to.
+	Path string
+
+	// Resume continues a previous download when Path already exists, only
+	// the missing part of the image is downloaded.
+	Resume bool
+
+	// Checksum is the expected checksum of the image in the form
+	// algorithm:hex, sha256 and md5 are supported. A hex string without
+	// algorithm is a sha256 checksum.
+	Checksum string
+
+	// Progress is called every time a chunk of the image is written.
+	Progress func(ImageProgress)
+
+	// Archive allows downloading the archive served for device types whose
+	// yocto build is compressed.
+	Archive bool
+}
+
+//ImageDownload downloads the resin OS image for the device type with the given
+//version from the image maker. An empty version downloads the latest version.
+//
+// For device types whose yocto build is compressed, the image maker serves an
+// archive instead of a raw disk image. ImageInjectConfig can't modify such
+// archives, so ErrCompressedImage is returned for them unless opts.Archive is
+// set.
+//
+//	err := ImageDownload(ctx, RaspberryPi3, "2.0.6+rev3", &ImageDownloadOptions{
+//		Path:   "resin.img",
+//		Resume: true,
+//	})
+func ImageDownload(ctx *Context, typ DeviceType, version string, opts *ImageDownloadOptions) error {
+	if opts == nil || opts.Path == "" {
+		return errors.New("resingo: missing image path")
+	}
+	check, err := newChecksum(opts.Checksum)
+	if err != nil {
+		return err
+	}
+	reg, err := DevTypeGetRegistry(ctx)
+	if err != nil {
+		return err
+	}
+	info, err := reg.Lookup(string(typ))
+	if err != nil {
+		return err
+	}
+	if info.Yocto.Compressed && !opts.Archive {
+		return ErrCompressedImage
+	}
+	slug := DeviceType(info.Slug)
+	cfg, err := ConfigGet(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg.ImageMakerURL == "" {
+		return errors.New("resingo: missing image maker url")
+	}
+	if version == "" {
+		version = "latest"
+	}
+	params := make(url.Values)
+	params.Set("version", version)
+	uri := fmt.Sprintf("%s/api/v1/image/%s/?%s",
+		strings.TrimSuffix(cfg.ImageMakerURL, "/"), slug, params.Encode())
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = authHeader(ctx.Config.AuthToken)
+
+	// ranges must refer to the bytes that are stored in the file.
+	req.Header.Set("Accept-Encoding", "identity")
+	var offset int64
+	if opts.Resume {
+		if st, err := os.Stat(opts.Path); err == nil {
+			offset = st.Size()
+		}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	flag := os.O_RDWR | os.O_CREATE
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+		flag |= os.O_TRUNC
+		total = resp.ContentLength
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != offset {
+			return fmt.Errorf("resingo: expected range from %d got %d", offset, start)
+		}
+		flag |= os.O_APPEND
+		total = size
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset == 0 {
+			return fmt.Errorf("resingo: [%d ] %s", resp.StatusCode, req.URL.RequestURI())
+		}
+
+		// the file already holds the whole image.
+		return verifyImage(opts.Path, check)
+	default:
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("resingo: [%d ] %s : %s", resp.StatusCode, req.URL.RequestURI(), string(b))
+	}
+	f, err := os.OpenFile(opts.Path, flag, 0644)
+	if err != nil {
+		return err
+	}
+	if check != nil && offset > 0 {
+		_, err = io.Copy(check, io.NewSectionReader(f, 0, offset))
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	var w io.Writer = f
+	if check != nil {
+		w = io.MultiWriter(f, check)
+	}
+	if opts.Progress != nil {
+		w = &progressWriter{w: w, fn: opts.Progress, p: ImageProgress{Written: offset, Total: total}}
+	}
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	if check != nil && !check.match() {
+		_ = os.Remove(opts.Path)
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// parses the Content-Range header of a partial response, the returned size is
+// -1 if it is unknown.
+func parseContentRange(v string) (int64, int64, error) {
+	var start, end int64
+	var size string
+	_, err := fmt.Sscanf(v, "bytes %d-%d/%s", &start, &end, &size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("resingo: bad content range %q", v)
+	}
+	if size == "*" {
+		return start, -1, nil
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("resingo: bad content range %q", v)
+	}
+	return start, n, nil
+}
+
+func verifyImage(path string, check *checksum) error {
+	if check == nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, err = io.Copy(check, f)
+	if err != nil {
+		return err
+	}
+	if !check.match() {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+type checksum struct {
+	hash.Hash
+	want []byte
+}
+
+func newChecksum(s string) (*checksum, error) {
+	if s == "" {
+		return nil, nil
+	}
+	algo, sum := "sha256", s
+	if i := strings.IndexRune(s, ':'); i != -1 {
+		algo, sum = strings.ToLower(s[:i]), s[i+1:]
+	}
+	want, err := hex.DecodeString(sum)
+	if err != nil {
+		return nil, fmt.Errorf("resingo: bad checksum %s", s)
+	}
+	c := &checksum{want: want}
+	switch algo {
+	case "sha256":
+		c.Hash = sha256.New()
+	case "md5":
+		c.Hash = md5.New()
+	default:
+		return nil, fmt.Errorf("resingo: unsupported checksum algorithm %s", algo)
+	}
+	if len(want) != c.Size() {
+		return nil, fmt.Errorf("resingo: bad checksum %s", s)
+	}
+	return c, nil
+}
+
+func (c *checksum) match() bool {
+	return bytes.Equal(c.Sum(nil), c.want)
+}
+
+type progressWriter struct {
+	w  io.Writer
+	fn func(ImageProgress)
+	p  ImageProgress
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.p.Written += int64(n)
+	p.fn(p.p)
+	return n, err
+}
@@ -0,0 +1,158 @@
+package resingo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImageDownload(t *testing.T) {
+	image := bytes.Repeat([]byte("resin os image "), 4096)
+	sum := sha256.Sum256(image)
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/image/intel-edison/" {
+			_, _ = w.Write([]byte("PK\x03\x04archive"))
+			return
+		}
+		if r.URL.Path != "/api/v1/image/raspberrypi3/" || r.URL.Query().Get("version") != "2.0.6+rev3" {
+			http.NotFound(w, r)
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "resin.img", time.Time{}, bytes.NewReader(image))
+	}))
+	defer ts.Close()
+	ctx := &Context{
+		Client: &http.Client{},
+		Config: &Config{},
+		ConfigCache: &ConfigCache{
+			cfg: &ResinConfig{
+				ImageMakerURL: ts.URL,
+				DeviceTypes: []DeviceTypeInfo{
+					{Slug: "raspberrypi3", Name: "Raspberry Pi 3"},
+					{Slug: "intel-edison", Name: "Intel Edison", Yocto: Yocto{Compressed: true}},
+				},
+			},
+			fetched: time.Now(),
+		},
+	}
+	dir, err := ioutil.TempDir("", "resingo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "resin.img")
+	t.Run("Download", func(ts *testing.T) {
+		var last ImageProgress
+		err := ImageDownload(ctx, RaspberryPi3, "2.0.6+rev3", &ImageDownloadOptions{
+			Path:     path,
+			Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+			Progress: func(p ImageProgress) {
+				last = p
+			},
+		})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if !bytes.Equal(b, image) {
+			ts.Error("expected the downloaded image to match")
+		}
+		if last.Written != int64(len(image)) || last.Total != int64(len(image)) {
+			ts.Errorf("expected %d bytes progress got %+v", len(image), last)
+		}
+	})
+	t.Run("Resume", func(ts *testing.T) {
+		err := ioutil.WriteFile(path, image[:1000], 0644)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		ranges = nil
+		err = ImageDownload(ctx, RaspberryPi3, "2.0.6+rev3", &ImageDownloadOptions{
+			Path:     path,
+			Resume:   true,
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+			ts.Errorf("expected a range request got %v", ranges)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if !bytes.Equal(b, image) {
+			ts.Error("expected the resumed image to match")
+		}
+
+		// the image is complete, nothing is left to download.
+		err = ImageDownload(ctx, RaspberryPi3, "2.0.6+rev3", &ImageDownloadOptions{
+			Path:     path,
+			Resume:   true,
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+		if err != nil {
+			ts.Fatal(err)
+		}
+	})
+	t.Run("Checksum", func(ts *testing.T) {
+		bad := sha256.Sum256([]byte("bad"))
+		err := ImageDownload(ctx, RaspberryPi3, "2.0.6+rev3", &ImageDownloadOptions{
+			Path:     path,
+			Checksum: hex.EncodeToString(bad[:]),
+		})
+		if err != ErrChecksumMismatch {
+			ts.Errorf("expected %v got %v", ErrChecksumMismatch, err)
+		}
+		_, err = os.Stat(path)
+		if !os.IsNotExist(err) {
+			ts.Error("expected the corrupt image to be removed")
+		}
+		err = ImageDownload(ctx, RaspberryPi3, "2.0.6+rev3", &ImageDownloadOptions{
+			Path:     path,
+			Checksum: "crc32:1234",
+		})
+		if err == nil {
+			ts.Error("expected an error for unsupported checksum")
+		}
+	})
+	t.Run("UnknownDeviceType", func(ts *testing.T) {
+		err := ImageDownload(ctx, DeviceType("toaster"), "", &ImageDownloadOptions{Path: path})
+		if err == nil {
+			ts.Error("expected an error")
+		}
+	})
+	t.Run("Compressed", func(ts *testing.T) {
+		archive := filepath.Join(dir, "edison.zip")
+		err := ImageDownload(ctx, DeviceType("intel-edison"), "", &ImageDownloadOptions{Path: archive})
+		if err != ErrCompressedImage {
+			ts.Fatalf("expected %v got %v", ErrCompressedImage, err)
+		}
+		if _, err = os.Stat(archive); !os.IsNotExist(err) {
+			ts.Errorf("expected nothing to be downloaded got %v", err)
+		}
+		err = ImageDownload(ctx, DeviceType("intel-edison"), "", &ImageDownloadOptions{Path: archive, Archive: true})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		err = ImageInjectConfig(archive, &DeviceConfig{UUID: "abc"})
+		if err != ErrCompressedImage {
+			ts.Errorf("expected %v got %v", ErrCompressedImage, err)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package resingo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+//DefaultPageSize is the number of devices fetched per request by DevIter.
+const DefaultPageSize = 100
+
+//DeviceIterator iterates over devices.
+//
+//	it := DevIter(ctx, appID, 0)
+//	for it.Next() {
+//		dev := it.Device()
+//		// use dev
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle error
+//	}
+type DeviceIterator interface {
+	// Next advances to the next device, it returns false when there are no
+	// more devices or an error happened.
+	Next() bool
+
+	// Device returns the current device.
+	Device() *Device
+
+	// Err returns the error that stopped the iteration.
+	Err() error
+}
+
+//NewDeviceSliceIterator returns an iterator over the given devices.
+func NewDeviceSliceIterator(devs []*Device) DeviceIterator {
+	return &sliceIterator{devs: devs, pos: -1}
+}
+
+type sliceIterator struct {
+	devs []*Device
+	pos  int
+}
+
+func (s *sliceIterator) Next() bool {
+	if s.pos+1 >= len(s.devs) {
+		s.pos = len(s.devs)
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *sliceIterator) Device() *Device {
+	if s.pos < 0 || s.pos >= len(s.devs) {
+		return nil
+	}
+	return s.devs[s.pos]
+}
+
+func (s *sliceIterator) Err() error {
+	return nil
+}
+
+//DevIter returns an iterator over the devices of the application with the
+//given appID which match all filters, devices are fetched pageSize at a time.
+//All devices of the user are iterated when appID is 0. A pageSize of 0 uses
+//DefaultPageSize.
+func DevIter(ctx *Context, appID int64, pageSize int, filters ...Filter) DeviceIterator {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if appID != 0 {
+		filters = append([]Filter{Filter(fmt.Sprintf("application eq %d", appID))}, filters...)
+	}
+	return &pageIterator{ctx: ctx, filters: filters, size: pageSize, pos: -1}
+}
+
+type pageIterator struct {
+	ctx     *Context
+	filters []Filter
+	size    int
+	skip    int
+	page    []*Device
+	pos     int
+	done    bool
+	err     error
+}
+
+func (p *pageIterator) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	if p.pos+1 < len(p.page) {
+		p.pos++
+		return true
+	}
+	if p.done {
+		return false
+	}
+	p.page, p.err = p.fetch()
+	p.pos = -1
+	if p.err != nil {
+		return false
+	}
+	p.skip += len(p.page)
+	if len(p.page) < p.size {
+		p.done = true
+	}
+	if len(p.page) == 0 {
+		return false
+	}
+	p.pos = 0
+	return true
+}
+
+func (p *pageIterator) fetch() ([]*Device, error) {
+	h := authHeader(p.ctx.Config.AuthToken)
+	uri := p.ctx.Config.APIEndpoint("device")
+	params := filterParams(p.filters)
+	if params == nil {
+		params = make(url.Values)
+	}
+	params.Set("$orderby", "id asc")
+	params.Set("$top", fmt.Sprint(p.size))
+	params.Set("$skip", fmt.Sprint(p.skip))
+	b, err := doJSON(p.ctx, "GET", uri, h, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res = struct {
+		D []*Device `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.D, nil
+}
+
+func (p *pageIterator) Device() *Device {
+	if p.pos < 0 || p.pos >= len(p.page) {
+		return nil
+	}
+	return p.page[p.pos]
+}
+
+func (p *pageIterator) Err() error {
+	return p.err
+}
@@ -0,0 +1,73 @@
+package resingo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDevIter(t *testing.T) {
+	var devs []*Device
+	for i := 1; i <= 25; i++ {
+		devs = append(devs, &Device{ID: int64(i)})
+	}
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/device" {
+			http.NotFound(w, r)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		q := r.URL.Query()
+		top, _ := strconv.Atoi(q.Get("$top"))
+		skip, _ := strconv.Atoi(q.Get("$skip"))
+		var page []*Device
+		for i := skip; i < skip+top && i < len(devs); i++ {
+			page = append(page, devs[i])
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": page})
+	}))
+	defer ts.Close()
+	ctx := &Context{
+		Client: &http.Client{},
+		Config: &Config{ResinEndpoint: ts.URL},
+	}
+	sample := []struct {
+		size     int
+		requests int
+	}{
+		{10, 3},
+		{5, 6},
+		{100, 1},
+	}
+	for _, v := range sample {
+		queries = nil
+		it := DevIter(ctx, 7, v.size)
+		var ids []int64
+		for it.Next() {
+			ids = append(ids, it.Device().ID)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != len(devs) || ids[0] != 1 || ids[len(ids)-1] != 25 {
+			t.Errorf("page size %d: expected all devices got %v", v.size, ids)
+		}
+		if len(queries) != v.requests {
+			t.Errorf("page size %d: expected %d requests got %d", v.size, v.requests, len(queries))
+		}
+		if !strings.Contains(queries[0], "$filter=application%20eq%207") {
+			t.Errorf("expected application filter got %s", queries[0])
+		}
+		if it.Next() {
+			t.Error("expected the iterator to stay done")
+		}
+	}
+	it := DevIter(&Context{Client: &http.Client{}, Config: &Config{ResinEndpoint: ts.URL + "/missing"}}, 0, 0)
+	if it.Next() || it.Err() == nil {
+		t.Error("expected an error")
+	}
+}
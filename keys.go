@@ -1,10 +1,21 @@
 package resingo
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 //Key is a user public key on resin
@@ -65,7 +76,8 @@ func KeyGetByID(ctx *Context, id int64) (*Key, error) {
 	return nil, errors.New("key not found")
 }
 
-//KeyCreate creates a public key for the user with given userID
+//KeyCreate creates a public key for the user with given userID. See KeyRegister
+//for registering keys for the user who authorized ctx with validation.
 func KeyCreate(ctx *Context, userID int64, key, title string) (*Key, error) {
 	h := authHeader(ctx.Config.AuthToken)
 	uri := ctx.Config.APIEndpoint("user__has__public_key")
@@ -83,7 +95,6 @@ func KeyCreate(ctx *Context, userID int64, key, title string) (*Key, error) {
 		return nil, err
 		//return nil
 	}
-	//fmt.Println(string(b))
 	e := &Key{}
 	err = json.Unmarshal(b, e)
 	if err != nil {
@@ -107,3 +118,202 @@ func KeyRemove(ctx *Context, id int64) error {
 	}
 	return nil
 }
+
+//MinRSAKeyBits is the minimum size of RSA keys accepted by ParsePublicKey.
+const MinRSAKeyBits = 2048
+
+//ErrDuplicateKey is returned by KeyRegister when a key with the same
+//fingerprint is already registered.
+var ErrDuplicateKey = errors.New("resingo: key is already registered")
+
+//PublicKeyInfo holds details about a ssh public key.
+type PublicKeyInfo struct {
+	Type              string
+	Bits              int
+	Comment           string
+	FingerprintSHA256 string
+	FingerprintMD5    string
+
+	// Path is the file the key was read from, it is only set by KeyDiscover.
+	Path string
+
+	// AuthorizedKey is the key in the authorized_keys format, without the
+	// comment.
+	AuthorizedKey string
+}
+
+//ParsePublicKey parses and validates a ssh public key in the authorized_keys
+//format, which is the format of the ~/.ssh/*.pub files.
+//
+// DSA keys and RSA keys smaller than MinRSAKeyBits are rejected.
+func ParsePublicKey(key string) (*PublicKeyInfo, error) {
+	pk, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("resingo: bad public key: %v", err)
+	}
+	info := &PublicKeyInfo{
+		Type:              pk.Type(),
+		Comment:           comment,
+		FingerprintSHA256: ssh.FingerprintSHA256(pk),
+		FingerprintMD5:    "MD5:" + ssh.FingerprintLegacyMD5(pk),
+		AuthorizedKey:     strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pk))),
+	}
+	ck, ok := pk.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, fmt.Errorf("resingo: unsupported key type %s", pk.Type())
+	}
+	switch k := ck.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		info.Bits = k.N.BitLen()
+		if info.Bits < MinRSAKeyBits {
+			return nil, fmt.Errorf("resingo: rsa key is too small, %d bits", info.Bits)
+		}
+	case *ecdsa.PublicKey:
+		info.Bits = k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.Bits = 256
+	default:
+		return nil, fmt.Errorf("resingo: unsupported key type %s", pk.Type())
+	}
+	return info, nil
+}
+
+//Info parses the public key.
+func (k *Key) Info() (*PublicKeyInfo, error) {
+	return ParsePublicKey(k.PublicKey)
+}
+
+//KeyRegister validates the public key and registers it for the user who
+//authorized ctx. If a key with the same fingerprint is already registered, the
+//registered key is returned with ErrDuplicateKey.
+func KeyRegister(ctx *Context, key, title string) (*Key, error) {
+	info, err := ParsePublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := KeyGetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		i, err := k.Info()
+		if err != nil {
+			continue
+		}
+		if i.FingerprintSHA256 == info.FingerprintSHA256 {
+			return k, ErrDuplicateKey
+		}
+	}
+	if title == "" {
+		title = info.Comment
+	}
+	return KeyCreate(ctx, ctx.Config.UserID(), key, title)
+}
+
+//KeyUpdateTitle changes the title of the public key with the given id.
+func KeyUpdateTitle(ctx *Context, id int64, title string) error {
+	h := authHeader(ctx.Config.AuthToken)
+	s := fmt.Sprintf("user__has__public_key(%d)", id)
+	uri := ctx.Config.APIEndpoint(s)
+	data := make(map[string]interface{})
+	data["title"] = title
+	body, err := marhsalReader(data)
+	if err != nil {
+		return err
+	}
+	b, err := doJSON(ctx, "PATCH", uri, h, nil, body)
+	if err != nil {
+		return err
+	}
+	if string(b) != "OK" {
+		return errors.New("bad response")
+	}
+	return nil
+}
+
+//KeyDiscover returns the valid public keys found in the *.pub files of dir.
+//The ~/.ssh directory is used when dir is empty. Files that don't contain a
+//valid key are skipped.
+func KeyDiscover(dir string) ([]*PublicKeyInfo, error) {
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".ssh")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	var rst []*PublicKeyInfo
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		info, err := ParsePublicKey(string(b))
+		if err != nil {
+			continue
+		}
+		info.Path = f
+		rst = append(rst, info)
+	}
+	return rst, nil
+}
+
+//KeyGenerate generates a new ed25519 key pair. It returns the PEM encoded
+//private key in the OpenSSH format and the public key in the authorized_keys
+//format, which can be registered with KeyRegister.
+func KeyGenerate(comment string) ([]byte, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return nil, "", err
+	}
+	pk, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, "", err
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pk)))
+	if comment != "" {
+		authorized += " " + comment
+	}
+	return pem.EncodeToMemory(block), authorized, nil
+}
+
+//KeyGenerateFile generates a new ed25519 key pair like ssh-keygen does. The
+//private key is written to the file with the given name and the public key to
+//the same file with a .pub extension. Existing files are not overwritten.
+func KeyGenerateFile(name, comment string) (string, error) {
+	priv, pub, err := KeyGenerate(comment)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{name, priv, 0600},
+		{name + ".pub", []byte(pub + "\n"), 0644},
+	} {
+		fd, err := os.OpenFile(f.name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.perm)
+		if err != nil {
+			return "", err
+		}
+		_, err = fd.Write(f.data)
+		if err != nil {
+			_ = fd.Close()
+			return "", err
+		}
+		err = fd.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return pub, nil
+}
@@ -3,6 +3,9 @@ package resingo
 import (
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -60,6 +63,33 @@ func TestKeys(t *testing.T) {
 			ts.Error("expected at least one key")
 		}
 	})
+	t.Run("Register", func(ts *testing.T) {
+		for _, v := range sample {
+			k, err := KeyRegister(ctx, v.key.PublicKey, "")
+			if err != ErrDuplicateKey {
+				ts.Errorf("expected %v got %v", ErrDuplicateKey, err)
+			}
+			if k == nil || k.ID != v.key.ID {
+				ts.Errorf("expected the registered key %d got %v", v.key.ID, k)
+			}
+		}
+	})
+	t.Run("UpdateTitle", func(ts *testing.T) {
+		for _, v := range sample {
+			title := v.title + "_updated"
+			err := KeyUpdateTitle(ctx, v.key.ID, title)
+			if err != nil {
+				ts.Fatal(err)
+			}
+			k, err := KeyGetByID(ctx, v.key.ID)
+			if err != nil {
+				ts.Fatal(err)
+			}
+			if k.Title != title {
+				ts.Errorf("expected %s got %s", title, k.Title)
+			}
+		}
+	})
 	t.Run("Remove", func(ts *testing.T) {
 		for _, v := range sample {
 			err := KeyRemove(ctx, v.key.ID)
@@ -69,3 +99,75 @@ func TestKeys(t *testing.T) {
 		}
 	})
 }
+
+func TestParsePublicKey(t *testing.T) {
+	d, err := ioutil.ReadFile("fixture/id_rsa.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParsePublicKey(string(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != "ssh-rsa" || info.Bits != 2048 {
+		t.Errorf("expected ssh-rsa 2048 got %s %d", info.Type, info.Bits)
+	}
+	if info.Comment != "gernest@MacBooks-MBP" {
+		t.Errorf("expected gernest@MacBooks-MBP got %s", info.Comment)
+	}
+	sha := "SHA256:4t5lAWm9Gn0L7BXRX5kCdOywNp+rEUrnFa40VJrnNoc"
+	if info.FingerprintSHA256 != sha {
+		t.Errorf("expected %s got %s", sha, info.FingerprintSHA256)
+	}
+	md5 := "MD5:a5:83:5a:8d:ba:51:2f:f7:86:21:22:e8:d4:75:5d:69"
+	if info.FingerprintMD5 != md5 {
+		t.Errorf("expected %s got %s", md5, info.FingerprintMD5)
+	}
+	_, err = ParsePublicKey("ssh-rsa garbage")
+	if err == nil {
+		t.Error("expected an error for a bad key")
+	}
+}
+
+func TestKeyGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resingo-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	name := filepath.Join(dir, "id_ed25519")
+	pub, err := KeyGenerateFile(name, "resingo@test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(pub, "ssh-ed25519 ") {
+		t.Errorf("expected an ed25519 key got %s", pub)
+	}
+	_, err = KeyGenerateFile(name, "resingo@test")
+	if err == nil {
+		t.Error("expected an error when overwriting a key")
+	}
+	st, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0600 {
+		t.Errorf("expected private key mode 0600 got %v", st.Mode().Perm())
+	}
+	keys, err := KeyDiscover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key got %d", len(keys))
+	}
+	k := keys[0]
+	if k.Path != name+".pub" || k.Type != "ssh-ed25519" || k.Bits != 256 {
+		t.Errorf("unexpected key %s %s %d", k.Path, k.Type, k.Bits)
+	}
+	if k.Comment != "resingo@test" {
+		t.Errorf("expected resingo@test got %s", k.Comment)
+	}
+}
@@ -23,7 +23,7 @@ type Logs struct {
 //NewLogs returns a new Logs instace which is initialized to support streaming
 //logs from pubnub.
 func NewLogs(ctx *Context) (*Logs, error) {
-	cfg, err := ConfigGetAll(ctx)
+	cfg, err := ConfigGet(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -1,67 +1,43 @@
 package resingo
 
-//DeviceType is the identity of the the device that is supported by resin.
-type DeviceType int
+//DeviceType is the slug of a device type that is supported by resin.
+//
+// The constants below are kept for compatibility, resin adds new boards all
+// the time. Any slug or alias known by the DeviceTypeRegistry is valid, for
+// instance DeviceType("raspberrypi3").
+type DeviceType string
 
 // supported devices
 const (
-	Artik10 DeviceType = iota
-	Artik5
-	BeagleboneBlack
-	HumingBoard
-	IntelAdison
-	IntelNuc
-	Nitrogen6x
-	OdroidC1
-	OdroidXu4
-	Parallella
-	RaspberryPi
-	RaspberryPi2
-	RaspberryPi3
-	Ts4900
-	Ts700
-	ViaVabx820Quad
-	ZyncXz702
+	Artik10         DeviceType = "artik10"
+	Artik5          DeviceType = "artik5"
+	BeagleboneBlack DeviceType = "beaglebone-black"
+	HumingBoard     DeviceType = "hummingboard"
+	IntelAdison     DeviceType = "intel-edison"
+	IntelNuc        DeviceType = "intel-nuc"
+	Nitrogen6x      DeviceType = "nitrogen6x"
+	OdroidC1        DeviceType = "odroid-c1"
+	OdroidXu4       DeviceType = "odroid-xu4"
+	Parallella      DeviceType = "parallella"
+	RaspberryPi     DeviceType = "raspberry-pi"
+	RaspberryPi2    DeviceType = "raspberry-pi2"
+	RaspberryPi3    DeviceType = "raspberrypi3"
+	Ts4900          DeviceType = "ts4900"
+	Ts7700          DeviceType = "ts7700"
+	ViaVabx820Quad  DeviceType = "via-vab820-quad"
+	ZyncXz702       DeviceType = "zynq-xz702"
+
+	// Ts700 is the old misspelled name of Ts7700.
+	//
+	// Deprecated: use Ts7700.
+	Ts700 = Ts7700
 )
 
 func (d DeviceType) String() string {
-	switch d {
-	case Artik10:
-		return "artik10"
-	case Artik5:
-		return "artik5"
-	case BeagleboneBlack:
-		return "beaglebone-black"
-	case HumingBoard:
-		return "hummingboard"
-	case IntelAdison:
-		return "intel-edison"
-	case IntelNuc:
-		return "intel-nuc"
-	case Nitrogen6x:
-		return "nitrogen6x"
-	case OdroidC1:
-		return "odroid-c1"
-	case OdroidXu4:
-		return "odroid-xu4"
-	case Parallella:
-		return "parallella"
-	case RaspberryPi:
-		return "raspberry-pi"
-	case RaspberryPi2:
-		return "raspberry-pi2"
-	case RaspberryPi3:
-		return "raspberrypi3"
-	case Ts4900:
-		return "ts4900"
-	case Ts700:
-		return "ts7700"
-	case ViaVabx820Quad:
-		return "via-vab820-quad"
-	case ZyncXz702:
-		return "zynq-xz702"
+	if d == "" {
+		return "Unknown"
 	}
-	return "Unknown"
+	return string(d)
 }
 
 //Repository is a resin remote repository
@@ -69,11 +45,3 @@ type Repository struct {
 	URL    string
 	Commit string
 }
-
-//User a resin user
-type User struct {
-	ID       int64 `json:"__id"`
-	Metadata struct {
-		URI string `json:"uri"`
-	} `json:"__deferred"`
-}
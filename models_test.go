@@ -22,6 +22,7 @@ func TestDeviceType(t *testing.T) {
 		{RaspberryPi3, "raspberrypi3"},
 		{Ts4900, "ts4900"},
 		{Ts700, "ts7700"},
+		{Ts7700, "ts7700"},
 		{ViaVabx820Quad, "via-vab820-quad"},
 		{ZyncXz702, "zynq-xz702"},
 	}
@@ -31,7 +32,7 @@ func TestDeviceType(t *testing.T) {
 			t.Errorf("expetcted %s got %v", v.expect, v.typ)
 		}
 	}
-	unkown := DeviceType(100)
+	unkown := DeviceType("")
 	if unkown.String() != "Unknown" {
 		t.Errorf("expected Unknown got %v", unkown)
 	}
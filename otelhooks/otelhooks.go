@@ -0,0 +1,77 @@
+//Package otelhooks traces the requests to the resin API with OpenTelemetry.
+//
+//	tracer := otel.Tracer("github.com/gernest/resingo")
+//	ctx.Hooks = otelhooks.New(tracer)
+//	ctx.Parent = r.Context()
+package otelhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gernest/resingo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//New returns hooks recording a client span with tracer for every request to
+//the resin API. The span is named after the method and the API resource, and
+//ends when the response body is closed. It is a child of the span in the
+//context of the request, and is propagated to the API with the global
+//propagator, see otel.SetTextMapPropagator.
+func New(tracer trace.Tracer) *resingo.Hooks {
+	var spans sync.Map
+	end := func(ev *resingo.RequestEvent) {
+		v, ok := spans.Load(ev)
+		if !ok {
+			return
+		}
+		spans.Delete(ev)
+		span := v.(trace.Span)
+		span.SetAttributes(
+			attribute.Int64("resingo.bytes_sent", ev.BytesSent),
+			attribute.Int64("resingo.bytes_received", ev.BytesReceived),
+		)
+		if ev.Status != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", ev.Status))
+		}
+		switch {
+		case ev.Err != nil:
+			span.RecordError(ev.Err)
+			span.SetStatus(codes.Error, ev.Err.Error())
+		case ev.Status >= 400:
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", ev.Status))
+		}
+		span.End()
+	}
+	return &resingo.Hooks{
+		OnRequest: func(ev *resingo.RequestEvent) {
+			parent := ev.Context
+			if parent == nil {
+				parent = context.Background()
+			}
+			c, span := tracer.Start(parent,
+				fmt.Sprintf("%s %s", ev.Method, ev.Resource),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("http.request.method", ev.Method),
+					attribute.String("url.full", ev.URL),
+					attribute.String("resingo.resource", ev.Resource),
+				),
+			)
+			h := make(http.Header)
+			otel.GetTextMapPropagator().Inject(c, propagation.HeaderCarrier(h))
+			for k := range h {
+				ev.SetHeader(k, h.Get(k))
+			}
+			spans.Store(ev, span)
+		},
+		OnResponse: end,
+		OnError:    end,
+	}
+}
@@ -0,0 +1,108 @@
+package otelhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gernest/resingo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	h := New(tp.Tracer("test"))
+	sample := []struct {
+		ev     *resingo.RequestEvent
+		failed bool
+		name   string
+		status codes.Code
+	}{
+		{&resingo.RequestEvent{Method: "GET", Resource: "device", Status: 200, BytesReceived: 42}, false, "GET device", codes.Unset},
+		{&resingo.RequestEvent{Method: "PATCH", Resource: "application", Status: 500}, false, "PATCH application", codes.Error},
+		{&resingo.RequestEvent{Method: "GET", Resource: "config", Err: errors.New("refused")}, true, "GET config", codes.Error},
+	}
+	for _, v := range sample {
+		h.OnRequest(v.ev)
+		if v.failed {
+			h.OnError(v.ev)
+		} else {
+			h.OnResponse(v.ev)
+		}
+	}
+	spans := rec.Ended()
+	if len(spans) != len(sample) {
+		t.Fatalf("expected %d spans got %d", len(sample), len(spans))
+	}
+	for i, v := range sample {
+		s := spans[i]
+		if s.Name() != v.name || s.Status().Code != v.status || s.SpanKind() != trace.SpanKindClient {
+			t.Errorf("expected %s %v got %s %v", v.name, v.status, s.Name(), s.Status())
+		}
+	}
+	var received int64
+	for _, a := range spans[0].Attributes() {
+		if a.Key == attribute.Key("resingo.bytes_received") {
+			received = a.Value.AsInt64()
+		}
+	}
+	if received != 42 {
+		t.Errorf("expected 42 bytes received got %d", received)
+	}
+	if len(spans[2].Events()) != 1 {
+		t.Errorf("expected the error to be recorded got %v", spans[2].Events())
+	}
+
+	// hooks without a started span are ignored
+	h.OnResponse(&resingo.RequestEvent{})
+	if len(rec.Ended()) != len(sample) {
+		t.Error("expected no new span")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		fmt.Fprint(w, `{"d":[{"id":1,"app_name":"fleet"}]}`)
+	}))
+	defer ts.Close()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	tracer := tp.Tracer("test")
+	parent, root := tracer.Start(context.Background(), "root")
+	ctx := &resingo.Context{
+		Client: &http.Client{},
+		Config: &resingo.Config{ResinEndpoint: ts.URL},
+		Hooks:  New(tracer),
+		Parent: parent,
+	}
+	if _, err := resingo.AppGetByID(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("expected the span to be a child of %s got %s", root.SpanContext().SpanID(), s.Parent().SpanID())
+	}
+	expect := fmt.Sprintf("00-%s-%s-01", s.SpanContext().TraceID(), s.SpanContext().SpanID())
+	if traceparent != expect {
+		t.Errorf("expected traceparent %s got %s", expect, traceparent)
+	}
+}
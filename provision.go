@@ -0,0 +1,292 @@
+package resingo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// default resin endpoints written to config.json.
+const (
+	DefaultVPNEndpoint      = "vpn.resin.io"
+	DefaultRegistryEndpoint = "registry.resin.io"
+	DefaultDeltaEndpoint    = "https://delta.resin.io"
+	DefaultVPNPort          = 443
+	DefaultListenPort       = 48484
+
+	// DefaultAppUpdatePollInterval is how often the supervisor checks for
+	// application updates.
+	DefaultAppUpdatePollInterval = 10 * time.Minute
+)
+
+//ErrNotLoggedIn is returned by functions which need the details of the logged
+//in user when the context was not logged in with Login.
+var ErrNotLoggedIn = errors.New("resingo: context is not logged in")
+
+//DeviceConfig is the config.json file of a resin device. It is read by resin OS
+//and the supervisor on boot.
+type DeviceConfig struct {
+	ApplicationName       string `json:"applicationName"`
+	ApplicationID         int64  `json:"applicationId"`
+	DeviceType            string `json:"deviceType"`
+	UserID                int64  `json:"userId"`
+	Username              string `json:"username"`
+	AppUpdatePollInterval string `json:"appUpdatePollInterval"`
+	ListenPort            int    `json:"listenPort"`
+	VPNPort               int    `json:"vpnPort"`
+	APIEndpoint           string `json:"apiEndpoint"`
+	VPNEndpoint           string `json:"vpnEndpoint"`
+	RegistryEndpoint      string `json:"registryEndpoint"`
+	DeltaEndpoint         string `json:"deltaEndpoint"`
+	PubNubSubscribeKey    string `json:"pubnubSubscribeKey"`
+	PubNubPublishKey      string `json:"pubnubPublishKey"`
+	MixpanelToken         string `json:"mixpanelToken"`
+	APIKey                string `json:"apiKey"`
+	UUID                  string `json:"uuid,omitempty"`
+	DeviceID              int64  `json:"deviceId,omitempty"`
+	RegisteredAt          int64  `json:"registered_at,omitempty"`
+	WifiSSID              string `json:"wifiSsid,omitempty"`
+	WifiKey               string `json:"wifiKey,omitempty"`
+
+	// Files are extra files for the boot partition, mapping the path
+	// relative to the boot partition to the file content. They are carried
+	// in config.json, network configuration is stored here.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+//JSON returns the indented config.json content.
+func (c *DeviceConfig) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+//NetworkOptions configures the network of a provisioned device. The device
+//uses ethernet with DHCP when no options are set.
+type NetworkOptions struct {
+	WifiSSID string
+
+	// WifiKey is the WPA passphrase of 8 to 63 characters, or the pre-shared
+	// key as 64 hex digits.
+	WifiKey string
+
+	// IP is the static address of the device in CIDR notation, for instance
+	// 192.168.1.20/24. DHCP is used when empty.
+	IP      string
+	Gateway string
+	DNS     []string
+}
+
+//ProvisioningOptions configures DevProvisioningConfig.
+type ProvisioningOptions struct {
+	// UUID of the device, a new one is generated when empty.
+	UUID string
+
+	// Register registers the device with resin before generating the
+	// configuration. Otherwise the device registers itself on first boot.
+	Register bool
+
+	Network *NetworkOptions
+
+	// AppUpdatePollInterval defaults to DefaultAppUpdatePollInterval.
+	AppUpdatePollInterval time.Duration
+
+	// The endpoints default to the resin.io endpoints.
+	VPNEndpoint      string
+	RegistryEndpoint string
+	DeltaEndpoint    string
+}
+
+//DevProvisioningConfig returns the config.json of a new device for the
+//application with the given appName.
+//
+//	cfg, err := DevProvisioningConfig(ctx, "fleet", &ProvisioningOptions{
+//		Network: &NetworkOptions{WifiSSID: "office", WifiKey: "secret123"},
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//	b, err := cfg.JSON()
+func DevProvisioningConfig(ctx *Context, appName string, opts *ProvisioningOptions) (*DeviceConfig, error) {
+	if opts == nil {
+		opts = &ProvisioningOptions{}
+	}
+	if ctx.Config.tokenClain == nil {
+		return nil, ErrNotLoggedIn
+	}
+	var files map[string]string
+	if opts.Network != nil {
+		var err error
+		files, err = opts.Network.files()
+		if err != nil {
+			return nil, err
+		}
+	}
+	app, err := AppGetByName(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := AppGetAPIKey(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	rcfg, err := ConfigGet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uuid := opts.UUID
+	if uuid == "" {
+		uuid, err = GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+	}
+	poll := opts.AppUpdatePollInterval
+	if poll == 0 {
+		poll = DefaultAppUpdatePollInterval
+	}
+	cfg := &DeviceConfig{
+		ApplicationName:       app.Name,
+		ApplicationID:         app.ID,
+		DeviceType:            app.DeviceType,
+		UserID:                ctx.Config.UserID(),
+		Username:              ctx.Config.tokenClain.Username,
+		AppUpdatePollInterval: strconv.FormatInt(int64(poll/time.Millisecond), 10),
+		ListenPort:            DefaultListenPort,
+		VPNPort:               DefaultVPNPort,
+		APIEndpoint:           ctx.Config.ResinEndpoint,
+		VPNEndpoint:           stringOr(opts.VPNEndpoint, DefaultVPNEndpoint),
+		RegistryEndpoint:      stringOr(opts.RegistryEndpoint, DefaultRegistryEndpoint),
+		DeltaEndpoint:         stringOr(opts.DeltaEndpoint, DefaultDeltaEndpoint),
+		PubNubSubscribeKey:    rcfg.PubNub.SubKey,
+		PubNubPublishKey:      rcfg.PubNub.PubKey,
+		MixpanelToken:         rcfg.MixPanelToken,
+		APIKey:                strings.Trim(string(key), `"`),
+		UUID:                  uuid,
+		Files:                 files,
+	}
+	if cfg.APIEndpoint == "" {
+		cfg.APIEndpoint = apiEndpoint
+	}
+	if opts.Network != nil {
+		cfg.WifiSSID = opts.Network.WifiSSID
+		cfg.WifiKey = opts.Network.WifiKey
+	}
+	if opts.Register {
+		dev, err := DevRegister(ctx, appName, uuid)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DeviceID = dev.ID
+		cfg.RegisteredAt = time.Now().Unix()
+	}
+	return cfg, nil
+}
+
+func stringOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// returns the NetworkManager connection files for the network options.
+func (n *NetworkOptions) files() (map[string]string, error) {
+	if n.WifiKey != "" && n.WifiSSID == "" {
+		return nil, errors.New("resingo: wifi key without ssid")
+	}
+	if n.WifiKey != "" && !validWifiKey(n.WifiKey) {
+		return nil, errors.New("resingo: wifi key must be a passphrase of 8 to 63 characters or 64 hex digits")
+	}
+	if hasControl(n.WifiSSID) || hasControl(n.WifiKey) {
+		return nil, errors.New("resingo: wifi ssid and key can't contain control characters")
+	}
+	ipv4 := "method=auto\n"
+	if n.IP != "" {
+		ip, ipnet, err := net.ParseCIDR(n.IP)
+		if err != nil {
+			return nil, fmt.Errorf("resingo: bad static ip: %v", err)
+		}
+		if ip.To4() == nil {
+			return nil, errors.New("resingo: static ip must be an ipv4 address")
+		}
+		ones, _ := ipnet.Mask.Size()
+		addr := fmt.Sprintf("%s/%d", ip, ones)
+		if n.Gateway != "" {
+			gw := net.ParseIP(n.Gateway)
+			if gw == nil || gw.To4() == nil {
+				return nil, fmt.Errorf("resingo: bad gateway %s", n.Gateway)
+			}
+			addr += "," + gw.String()
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "method=manual\naddress1=%s\n", addr)
+		if len(n.DNS) > 0 {
+			for _, d := range n.DNS {
+				if ip := net.ParseIP(d); ip == nil || ip.To4() == nil {
+					return nil, fmt.Errorf("resingo: bad dns server %s", d)
+				}
+			}
+			fmt.Fprintf(&buf, "dns=%s;\n", strings.Join(n.DNS, ";"))
+		}
+		ipv4 = buf.String()
+	} else if n.Gateway != "" || len(n.DNS) > 0 {
+		return nil, errors.New("resingo: gateway and dns need a static ip")
+	}
+	if n.WifiSSID == "" && n.IP == "" {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	name := "resin-ethernet"
+	if n.WifiSSID != "" {
+		name = "resin-wifi"
+		fmt.Fprintf(&buf, "[connection]\nid=%s\ntype=wifi\n\n", name)
+		fmt.Fprintf(&buf, "[wifi]\nhidden=true\nmode=infrastructure\nssid=%s\n\n", keyfileEscape(n.WifiSSID))
+		if n.WifiKey != "" {
+			fmt.Fprintf(&buf, "[wifi-security]\nauth-alg=open\nkey-mgmt=wpa-psk\npsk=%s\n\n", keyfileEscape(n.WifiKey))
+		}
+	} else {
+		fmt.Fprintf(&buf, "[connection]\nid=%s\ntype=ethernet\n\n", name)
+	}
+	fmt.Fprintf(&buf, "[ipv4]\n%s\n", ipv4)
+	fmt.Fprintf(&buf, "[ipv6]\naddr-gen-mode=stable-privacy\nmethod=auto\n")
+	return map[string]string{
+		"system-connections/" + name: buf.String(),
+	}, nil
+}
+
+// reports whether key is a WPA passphrase or a hex encoded pre-shared key.
+func validWifiKey(key string) bool {
+	if len(key) >= 8 && len(key) <= 63 {
+		return true
+	}
+	if len(key) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+func hasControl(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// escapes s as a keyfile value, the way GKeyFile does. Backslashes start
+// escape sequences and leading spaces are trimmed by the parser.
+func keyfileEscape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	if strings.HasPrefix(s, " ") {
+		s = `\s` + s[1:]
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package resingo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProvisioning(t *testing.T) {
+	config := &Config{
+		Username:      ENV.Username,
+		Password:      ENV.Password,
+		ResinEndpoint: apiEndpoint,
+	}
+	client := &http.Client{}
+	ctx := &Context{
+		Client: client,
+		Config: config,
+	}
+	err := Login(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appName := "provisioning_test"
+	app, err := AppCreate(ctx, appName, RaspberryPi3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = AppDelete(ctx, app.ID)
+	}()
+	cfg, err := DevProvisioningConfig(ctx, appName, &ProvisioningOptions{
+		Register: true,
+		Network: &NetworkOptions{
+			WifiSSID: "resingo",
+			WifiKey:  "supersecret",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ApplicationID != app.ID {
+		t.Errorf("expected %d got %d", app.ID, cfg.ApplicationID)
+	}
+	if cfg.APIKey == "" {
+		t.Error("expected the application api key")
+	}
+	dev, err := DevGetByUUID(ctx, cfg.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.ID != cfg.DeviceID {
+		t.Errorf("expected %d got %d", dev.ID, cfg.DeviceID)
+	}
+}
+
+func TestDeviceConfigJSON(t *testing.T) {
+	cfg := &DeviceConfig{
+		ApplicationName: "fleet",
+		ApplicationID:   12,
+		UUID:            "abc",
+		WifiSSID:        "office",
+	}
+	b, err := cfg.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"applicationName", "applicationId", "uuid", "wifiSsid"} {
+		if _, ok := v[k]; !ok {
+			t.Errorf("expected %s in config.json", k)
+		}
+	}
+	if _, ok := v["deviceId"]; ok {
+		t.Error("expected deviceId to be omitted")
+	}
+}
+
+func TestNetworkOptions(t *testing.T) {
+	sample := []struct {
+		opts     NetworkOptions
+		file     string
+		contains []string
+	}{
+		{
+			NetworkOptions{WifiSSID: "office", WifiKey: "supersecret"},
+			"system-connections/resin-wifi",
+			[]string{"type=wifi", "ssid=office", "psk=supersecret", "[ipv4]\nmethod=auto"},
+		},
+		{
+			NetworkOptions{IP: "192.168.1.20/24", Gateway: "192.168.1.1", DNS: []string{"8.8.8.8", "8.8.4.4"}},
+			"system-connections/resin-ethernet",
+			[]string{"type=ethernet", "method=manual", "address1=192.168.1.20/24,192.168.1.1", "dns=8.8.8.8;8.8.4.4;"},
+		},
+		{
+			NetworkOptions{WifiSSID: ` my\net`, WifiKey: `pass\word`},
+			"system-connections/resin-wifi",
+			[]string{"ssid=\\smy\\\\net\n", "psk=pass\\\\word\n"},
+		},
+		{
+			NetworkOptions{WifiSSID: "office", WifiKey: strings.Repeat("p", 63)},
+			"system-connections/resin-wifi",
+			[]string{"psk=" + strings.Repeat("p", 63) + "\n"},
+		},
+		{
+			NetworkOptions{WifiSSID: "office", WifiKey: strings.Repeat("0123456789abcDEF", 4)},
+			"system-connections/resin-wifi",
+			[]string{"psk=" + strings.Repeat("0123456789abcDEF", 4) + "\n"},
+		},
+	}
+	for _, v := range sample {
+		files, err := v.opts.files()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, ok := files[v.file]
+		if !ok {
+			t.Fatalf("expected %s got %v", v.file, files)
+		}
+		for _, c := range v.contains {
+			if !strings.Contains(f, c) {
+				t.Errorf("expected %q in %s", c, f)
+			}
+		}
+	}
+	files, err := (&NetworkOptions{}).files()
+	if err != nil || files != nil {
+		t.Errorf("expected no files got %v %v", files, err)
+	}
+	bad := []NetworkOptions{
+		{WifiKey: "supersecret"},
+		{WifiSSID: "office", WifiKey: "short"},
+		{WifiSSID: "office", WifiKey: strings.Repeat("g", 64)},
+		{WifiSSID: "office", WifiKey: strings.Repeat("a", 65)},
+		{IP: "192.168.1.300/24"},
+		{IP: "192.168.1.20/24", Gateway: "gateway"},
+		{Gateway: "192.168.1.1"},
+		{WifiSSID: "office\n[ipv4]\nmethod=manual"},
+		{WifiSSID: "office", WifiKey: "supersecret\n[connection]"},
+		{IP: "192.168.1.20/24", DNS: []string{"2001:4860:4860::8888"}},
+	}
+	for _, v := range bad {
+		_, err := v.files()
+		if err == nil {
+			t.Errorf("expected an error for %+v", v)
+		}
+	}
+}
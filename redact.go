@@ -0,0 +1,175 @@
+package resingo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//Redacted replaces sensitive values like tokens, passwords and API keys in
+//everything the library reports about its requests.
+const Redacted = "[REDACTED]"
+
+// headers carrying credentials.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// parts of query, form and JSON keys naming credentials, they are matched
+// case insensitively.
+var sensitiveKeys = []string{"password", "apikey", "api_key", "token", "secret"}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, v := range sensitiveKeys {
+		if strings.Contains(key, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// endpoints whose whole response body is a credential, the session tokens
+// returned by Authenticate and UserRegister and the key returned by
+// AppGetAPIKey.
+var secretPaths = []string{"/login_", "/user/register", "/generate-api-key"}
+
+func isSecretResponse(u *url.URL) bool {
+	for _, v := range secretPaths {
+		if strings.HasSuffix(u.Path, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether the whole body b is a JWT, like the session tokens returned
+// by endpoints missing from secretPaths.
+func isToken(b []byte) bool {
+	parts := strings.Split(strings.TrimSpace(string(b)), ".")
+	if len(parts) != 3 {
+		return false
+	}
+	head, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[0], "="))
+	if err != nil {
+		return false
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	return json.Unmarshal(head, &h) == nil && h.Alg != ""
+}
+
+// returns a copy of h with the credentials replaced. The scheme of the
+// Authorization header is kept, so Bearer tokens can be told apart from other
+// kinds of authorization.
+func redactHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	for _, k := range sensitiveHeaders {
+		v := c[k]
+		for i := range v {
+			p := strings.IndexByte(v[i], ' ')
+			if strings.HasSuffix(k, "Authorization") && p != -1 {
+				v[i] = v[i][:p+1] + Redacted
+				continue
+			}
+			v[i] = Redacted
+		}
+	}
+	return c
+}
+
+// returns u with the password of the user info and sensitive query values
+// replaced.
+func redactURL(u *url.URL) string {
+	c := *u
+	if _, ok := c.User.Password(); ok {
+		c.User = url.UserPassword(c.User.Username(), Redacted)
+	}
+	if c.RawQuery != "" {
+		q, err := url.ParseQuery(c.RawQuery)
+		if err == nil && redactValues(q) {
+			c.RawQuery = q.Encode()
+		}
+	}
+	return c.String()
+}
+
+// replaces sensitive values in place and reports whether any was found.
+func redactValues(v url.Values) bool {
+	found := false
+	for k, s := range v {
+		if isSensitiveKey(k) {
+			for i := range s {
+				s[i] = Redacted
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// returns a copy of the body b of a request to, or a response from u with the
+// credentials replaced. Form and JSON bodies are supported, other bodies are
+// returned as they are.
+func redactBody(u *url.URL, contentType string, b []byte, response bool) []byte {
+	if len(b) == 0 {
+		return b
+	}
+	if response && (isSecretResponse(u) || isToken(b)) {
+		return []byte(Redacted)
+	}
+	typ, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case typ == "application/x-www-form-urlencoded":
+		v, err := url.ParseQuery(string(b))
+		if err != nil || !redactValues(v) {
+			return b
+		}
+		return []byte(v.Encode())
+	case typ == "application/json" || strings.HasSuffix(typ, "+json"):
+		var o interface{}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&o); err != nil || !redactJSON(o) {
+			return b
+		}
+		rst, err := json.Marshal(o)
+		if err != nil {
+			return b
+		}
+		return rst
+	}
+	return b
+}
+
+// replaces sensitive values of the decoded JSON document o in place and
+// reports whether any was found.
+func redactJSON(o interface{}) bool {
+	found := false
+	switch v := o.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if isSensitiveKey(k) {
+				v[k] = Redacted
+				found = true
+				continue
+			}
+			if redactJSON(e) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if redactJSON(e) {
+				found = true
+			}
+		}
+	}
+	return found
+}
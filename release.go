@@ -0,0 +1,195 @@
+package resingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/guregu/null"
+)
+
+//ErrReleaseNotFound is returned when there is no release matching the query.
+var ErrReleaseNotFound = errors.New("resingo: release not found")
+
+// release statuses reported by the resin builder.
+const (
+	ReleaseRunning     = "running"
+	ReleaseSuccess     = "success"
+	ReleaseFailed      = "failed"
+	ReleaseError       = "error"
+	ReleaseInterrupted = "interrupted"
+)
+
+//Release is a version of an application, it is created every time code is
+//pushed to the application.
+type Release struct {
+	ID             int64     `json:"id"`
+	Commit         string    `json:"commit"`
+	Status         string    `json:"status"`
+	Source         string    `json:"source"`
+	Log            string    `json:"log"`
+	CreatedAt      null.Time `json:"created_at"`
+	StartTimestamp null.Time `json:"start_timestamp"`
+	EndTimestamp   null.Time `json:"end_timestamp"`
+	Application    struct {
+		ID       int64 `json:"__id"`
+		Metadata struct {
+			URI string `json:"uri"`
+		} `json:"__deferred"`
+	} `json:"application"`
+
+	// Images are the builds of the release, they are only available on
+	// releases retrieved with ReleaseGetByID.
+	Images   []*Build `json:"image"`
+	Metadata struct {
+		URI  string `json:"uri"`
+		Type string `json:"type"`
+	} `json:"__metadata"`
+}
+
+//Build is the container image built for a release.
+type Build struct {
+	ID             int64     `json:"id"`
+	Status         string    `json:"status"`
+	ContentHash    string    `json:"content_hash"`
+	ImageSize      int64     `json:"image_size"`
+	Dockerfile     string    `json:"dockerfile"`
+	ProjectType    string    `json:"project_type"`
+	ImageLocation  string    `json:"is_stored_at__image_location"`
+	BuildLog       string    `json:"build_log"`
+	ErrorMessage   string    `json:"error_message"`
+	PushTimestamp  null.Time `json:"push_timestamp"`
+	StartTimestamp null.Time `json:"start_timestamp"`
+	EndTimestamp   null.Time `json:"end_timestamp"`
+}
+
+//IsSuccessful returns true if the release was built successfully.
+func (r *Release) IsSuccessful() bool {
+	return r.Status == ReleaseSuccess
+}
+
+//ReleaseGetAll returns all releases of the application with the given appID.
+func ReleaseGetAll(ctx *Context, appID int64) ([]*Release, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("release")
+	params := make(url.Values)
+	params.Set("filter", "application")
+	params.Set("eq", fmt.Sprint(appID))
+	b, err := doJSON(ctx, "GET", uri, h, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res = struct {
+		D []*Release `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.D, nil
+}
+
+//ReleaseGetByID returns the release with the given id, including the images
+//that were built for it.
+func ReleaseGetByID(ctx *Context, id int64) (*Release, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(fmt.Sprintf("release(%d)", id))
+	params := make(url.Values)
+	params.Set("expand", "image")
+	b, err := doJSON(ctx, "GET", uri, h, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res = struct {
+		D []*Release `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.D) > 0 {
+		return res.D[0], nil
+	}
+	return nil, ErrReleaseNotFound
+}
+
+//ReleaseGetByCommit returns the release of the application with the given
+//appID which was built from commit.
+func ReleaseGetByCommit(ctx *Context, appID int64, commit string) (*Release, error) {
+	rels, err := ReleaseGetAll(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rels {
+		if r.Commit == commit {
+			return r, nil
+		}
+	}
+	return nil, ErrReleaseNotFound
+}
+
+//AppPinRelease pins the application with the given appID to the release with
+//the given id. All devices of the application will run the release until the
+//application is unpinned with AppUnpinRelease.
+//
+// Only successful releases can be pinned.
+func AppPinRelease(ctx *Context, appID, id int64) (*Application, error) {
+	rel, err := ReleaseGetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !rel.IsSuccessful() {
+		return nil, fmt.Errorf("resingo: can't pin release %d with status %s", id, rel.Status)
+	}
+	data := make(map[string]interface{})
+	data["commit"] = rel.Commit
+	data["should_track_latest_release"] = false
+	return appUpdate(ctx, appID, data)
+}
+
+//AppUnpinRelease makes the application with the given appID track the latest
+//release again.
+func AppUnpinRelease(ctx *Context, appID int64) (*Application, error) {
+	data := make(map[string]interface{})
+	data["should_track_latest_release"] = true
+	return appUpdate(ctx, appID, data)
+}
+
+//DevPinRelease pins the device with the given devID to the release with the
+//given id, regardless of the release the application is running.
+func DevPinRelease(ctx *Context, devID, id int64) error {
+	rel, err := ReleaseGetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !rel.IsSuccessful() {
+		return fmt.Errorf("resingo: can't pin release %d with status %s", id, rel.Status)
+	}
+	return devSetRelease(ctx, devID, rel.ID)
+}
+
+//DevUnpinRelease makes the device with the given devID follow the release of
+//its application again.
+func DevUnpinRelease(ctx *Context, devID int64) error {
+	return devSetRelease(ctx, devID, nil)
+}
+
+func devSetRelease(ctx *Context, devID int64, release interface{}) error {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(fmt.Sprintf("device(%d)", devID))
+	data := make(map[string]interface{})
+	data["should_be_running__release"] = release
+	body, err := marhsalReader(data)
+	if err != nil {
+		return err
+	}
+	b, err := doJSON(ctx, "PATCH", uri, h, nil, body)
+	if err != nil {
+		return err
+	}
+	if string(b) != "OK" {
+		return errors.New("bad response")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package resingo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRelease(t *testing.T) {
+	config := &Config{
+		Username:      ENV.Username,
+		Password:      ENV.Password,
+		ResinEndpoint: apiEndpoint,
+	}
+	client := &http.Client{}
+	ctx := &Context{
+		Client: client,
+		Config: config,
+	}
+	err := Login(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := AppCreate(ctx, "release_test", RaspberryPi3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = AppDelete(ctx, app.ID)
+	}()
+	t.Run("GetAll", func(ts *testing.T) {
+		rels, err := ReleaseGetAll(ctx, app.ID)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if len(rels) != 0 {
+			ts.Errorf("expected no releases got %d", len(rels))
+		}
+	})
+	t.Run("GetByCommit", func(ts *testing.T) {
+		_, err := ReleaseGetByCommit(ctx, app.ID, "deadbeef")
+		if err != ErrReleaseNotFound {
+			ts.Errorf("expected %v got %v", ErrReleaseNotFound, err)
+		}
+	})
+	t.Run("Unpin", func(ts *testing.T) {
+		a, err := AppUnpinRelease(ctx, app.ID)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if !a.TrackLatest {
+			ts.Error("expected the application to track the latest release")
+		}
+	})
+}
+
+func TestReleasePin(t *testing.T) {
+	type patch struct {
+		path string
+		body map[string]interface{}
+	}
+	var (
+		patches []patch
+		queries []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PATCH":
+			b, _ := ioutil.ReadAll(r.Body)
+			p := patch{path: r.URL.Path}
+			if err := json.Unmarshal(b, &p.body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			patches = append(patches, p)
+			fmt.Fprint(w, "OK")
+		case r.URL.Path == "/v1/release(5)":
+			q, _ := url.QueryUnescape(r.URL.RawQuery)
+			queries = append(queries, q)
+			fmt.Fprint(w, `{"d":[{"id":5,"commit":"good","status":"success","image":[{"id":1,"status":"success"}]}]}`)
+		case r.URL.Path == "/v1/release":
+			q, _ := url.QueryUnescape(r.URL.RawQuery)
+			queries = append(queries, q)
+			fmt.Fprint(w, `{"d":[{"id":4,"commit":"old","status":"success"},{"id":5,"commit":"good","status":"success"}]}`)
+		case r.URL.Path == "/v1/release(6)":
+			fmt.Fprint(w, `{"d":[{"id":6,"commit":"bad","status":"failed"}]}`)
+		case r.URL.Path == "/v1/release(7)":
+			fmt.Fprint(w, `{"d":[]}`)
+		case r.URL.Path == "/v1/application(1)":
+			fmt.Fprint(w, `{"d":[{"id":1,"app_name":"fleet","commit":"good"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	ctx := &Context{
+		Client: &http.Client{},
+		Config: &Config{ResinEndpoint: ts.URL},
+	}
+	rel, err := ReleaseGetByCommit(ctx, 1, "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := AppPinRelease(ctx, 1, rel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Commit != "good" {
+		t.Errorf("expected the pinned application got %+v", app)
+	}
+	if len(queries) != 2 || queries[0] != "$filter=application eq 1" {
+		t.Errorf("expected the releases of the application to be fetched got %v", queries)
+	}
+	if len(queries) != 2 || !strings.Contains(queries[1], "$expand=image") {
+		t.Errorf("expected the release to be fetched with its images got %v", queries)
+	}
+	if _, err = AppUnpinRelease(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = DevPinRelease(ctx, 3, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err = DevUnpinRelease(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	expect := []patch{
+		{"/v1/application(1)", map[string]interface{}{"commit": "good", "should_track_latest_release": false}},
+		{"/v1/application(1)", map[string]interface{}{"should_track_latest_release": true}},
+		{"/v1/device(3)", map[string]interface{}{"should_be_running__release": float64(5)}},
+		{"/v1/device(3)", map[string]interface{}{"should_be_running__release": nil}},
+	}
+	if len(patches) != len(expect) {
+		t.Fatalf("expected %d patches got %v", len(expect), patches)
+	}
+	for i, v := range expect {
+		p := patches[i]
+		if p.path != v.path || fmt.Sprint(p.body) != fmt.Sprint(v.body) {
+			t.Errorf("%d: expected %s %v got %s %v", i, v.path, v.body, p.path, p.body)
+		}
+	}
+
+	// failed and missing releases can't be pinned.
+	patches = nil
+	if _, err = AppPinRelease(ctx, 1, 6); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("expected an error for a failed release got %v", err)
+	}
+	if err = DevPinRelease(ctx, 3, 6); err == nil {
+		t.Error("expected an error for a failed release")
+	}
+	if err = DevPinRelease(ctx, 3, 7); err != ErrReleaseNotFound {
+		t.Errorf("expected %v got %v", ErrReleaseNotFound, err)
+	}
+	if len(patches) != 0 {
+		t.Errorf("expected nothing to be pinned got %v", patches)
+	}
+}
@@ -2,6 +2,7 @@ package resingo
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -73,6 +74,19 @@ type HTTPClient interface {
 type Context struct {
 	Client HTTPClient
 	Config *Config
+
+	// ConfigCache caches the resin configuration, it is created on first use
+	// by ConfigGet when nil.
+	ConfigCache *ConfigCache
+
+	// Hooks are called for every request sent to the resin API, nil disables
+	// them.
+	Hooks *Hooks
+
+	// Parent is the context requests to the resin API are sent with, they are
+	// canceled when it is done and hooks see it as RequestEvent.Context. It
+	// defaults to context.Background().
+	Parent context.Context
 }
 
 //Config is the configuration object for the Client
@@ -84,6 +98,21 @@ type Config struct {
 	tokenClain    *TokenClain
 	ResinEndpoint string
 	ResinVersion  APIVersion
+
+	// ActionsEndpoint is the endpoint of the resin actions service which
+	// runs host OS updates. It defaults to DefaultActionsEndpoint.
+	ActionsEndpoint string
+
+	// Debug dumps every request and its response to DebugOutput, which
+	// defaults to os.Stderr. Requests are written as curl commands and
+	// credentials are redacted. Setting the RESINGO_DEBUG environment
+	// variable to true has the same effect.
+	Debug       bool
+	DebugOutput io.Writer
+
+	// DebugMaxBody is the number of bytes of a body dumped, it defaults to
+	// DefaultDebugMaxBody. Bodies are not truncated when it is negative.
+	DebugMaxBody int
 }
 
 //TokenClain are the values that are encoded into a session token from resin.io.
@@ -183,9 +212,12 @@ func Authenticate(ctx *Context, typ AuthType, authToken ...string) (string, erro
 		form := url.Values{}
 		form.Add("username", ctx.Config.Username)
 		form.Add("password", ctx.Config.Password)
-		res, err := ctx.Client.Post(loginURL,
-			"application/x-www-form-urlencoded",
-			strings.NewReader(form.Encode()))
+		req, err := http.NewRequest("POST", loginURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res, err := send(ctx, req)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,383 @@
+package resingo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+//SecretPrefix is the prefix of encrypted variable values.
+const SecretPrefix = "enc:v1:"
+
+// size of the data keys used to encrypt values.
+const secretKeySize = 32
+
+//ErrBadSecret is returned when an encrypted value can't be decoded or
+//decrypted.
+var ErrBadSecret = errors.New("resingo: bad encrypted value")
+
+//KeyProvider provides the key encryption keys for secret variables.
+//
+// Values are encrypted with envelope encryption, every value is encrypted with
+// its own random data key, which is then encrypted(wrapped) by the provider.
+// The provider never sees the values, so it can be backed by a KMS.
+type KeyProvider interface {
+	// KeyID returns the id of the key used by Wrap.
+	KeyID() string
+
+	// Wrap encrypts the data key with the current key.
+	Wrap(dataKey []byte) ([]byte, error)
+
+	// Unwrap decrypts a data key that was wrapped by the key with the given
+	// id.
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+//LocalKeyProvider is a KeyProvider that uses AES keys stored in local files.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+//NewLocalKeyProvider returns a provider for the given 32 bytes AES keys. The
+//first key is used to wrap new data keys, the rest are only used to unwrap
+//keys, which allows to rotate keys.
+func NewLocalKeyProvider(keys ...[]byte) (*LocalKeyProvider, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("resingo: missing keys")
+	}
+	p := &LocalKeyProvider{keys: make(map[string][]byte)}
+	for i, k := range keys {
+		if len(k) != secretKeySize {
+			return nil, fmt.Errorf("resingo: key must be %d bytes got %d", secretKeySize, len(k))
+		}
+		id := localKeyID(k)
+		if i == 0 {
+			p.current = id
+		}
+		p.keys[id] = k
+	}
+	return p, nil
+}
+
+//LoadLocalKeyProvider returns a LocalKeyProvider using the base64 encoded keys
+//stored in the given files. The key in the first file is the current key.
+func LoadLocalKeyProvider(files ...string) (*LocalKeyProvider, error) {
+	var keys [][]byte
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, fmt.Errorf("resingo: bad key in %s: %v", f, err)
+		}
+		keys = append(keys, k)
+	}
+	return NewLocalKeyProvider(keys...)
+}
+
+//GenerateLocalKey generates a new random key and writes it base64 encoded to
+//the file with the given name. The file must not exist.
+func GenerateLocalKey(name string) error {
+	k := make([]byte, secretKeySize)
+	_, err := rand.Read(k)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(base64.StdEncoding.EncodeToString(k) + "\n")
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// the key id is derived from the key, so the same key always has the same id.
+func localKeyID(k []byte) string {
+	s := sha256.Sum256(k)
+	return "local:" + hex.EncodeToString(s[:8])
+}
+
+//KeyID implements KeyProvider.
+func (p *LocalKeyProvider) KeyID() string {
+	return p.current
+}
+
+//Wrap implements KeyProvider.
+func (p *LocalKeyProvider) Wrap(dataKey []byte) ([]byte, error) {
+	return sealGCM(p.keys[p.current], dataKey, []byte(p.current))
+}
+
+//Unwrap implements KeyProvider.
+func (p *LocalKeyProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	k, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("resingo: unknown key %s", keyID)
+	}
+	return openGCM(k, wrapped, []byte(keyID))
+}
+
+func sealGCM(key, plain, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, aad), nil
+}
+
+func openGCM(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrBadSecret
+	}
+	n := gcm.NonceSize()
+	plain, err := gcm.Open(nil, sealed[:n], sealed[n:], aad)
+	if err != nil {
+		return nil, ErrBadSecret
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+//IsSecret returns true if value is an encrypted value.
+func IsSecret(value string) bool {
+	return strings.HasPrefix(value, SecretPrefix)
+}
+
+//EncryptValue encrypts the value of the variable with the given name. The
+//returned value has the form enc:v1:<base64>.
+//
+// The name is authenticated together with the value, so the encrypted value
+// can't be decrypted as the value of another variable.
+func EncryptValue(kp KeyProvider, name, value string) (string, error) {
+	dataKey := make([]byte, secretKeySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := kp.Wrap(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := sealGCM(dataKey, []byte(value), []byte(name))
+	if err != nil {
+		return "", err
+	}
+	id := kp.KeyID()
+	if len(id) > 255 {
+		return "", errors.New("resingo: key id is too long")
+	}
+
+	// [key id length][key id][wrapped key length][wrapped key][sealed value]
+	buf := make([]byte, 0, 3+len(id)+len(wrapped)+len(sealed))
+	buf = append(buf, byte(len(id)))
+	buf = append(buf, id...)
+	buf = append(buf, 0, 0)
+	binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(len(wrapped)))
+	buf = append(buf, wrapped...)
+	buf = append(buf, sealed...)
+	return SecretPrefix + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+//DecryptValue decrypts the value of the variable with the given name. Values
+//that are not encrypted are returned as they are.
+func DecryptValue(kp KeyProvider, name, value string) (string, error) {
+	if !IsSecret(value) {
+		return value, nil
+	}
+	id, wrapped, sealed, err := decodeSecret(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := kp.Unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plain, err := openGCM(dataKey, sealed, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+//SecretKeyID returns the id of the key that was used to encrypt value.
+func SecretKeyID(value string) (string, error) {
+	if !IsSecret(value) {
+		return "", ErrBadSecret
+	}
+	id, _, _, err := decodeSecret(value)
+	return id, err
+}
+
+func decodeSecret(value string) (string, []byte, []byte, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SecretPrefix))
+	if err != nil || len(b) < 1 {
+		return "", nil, nil, ErrBadSecret
+	}
+	n := int(b[0])
+	b = b[1:]
+	if len(b) < n+2 {
+		return "", nil, nil, ErrBadSecret
+	}
+	id := string(b[:n])
+	b = b[n:]
+	w := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < w {
+		return "", nil, nil, ErrBadSecret
+	}
+	return id, b[:w], b[w:], nil
+}
+
+//DecryptEnviron decrypts all encrypted variables in the environment of the
+//current process. This is meant to be called early by applications running on
+//the device.
+func DecryptEnviron(kp KeyProvider) error {
+	for _, kv := range os.Environ() {
+		i := strings.IndexRune(kv, '=')
+		if i == -1 {
+			continue
+		}
+		name, value := kv[:i], kv[i+1:]
+		if !IsSecret(value) {
+			continue
+		}
+		plain, err := DecryptValue(kp, name, value)
+		if err != nil {
+			return fmt.Errorf("resingo: decrypting %s: %v", name, err)
+		}
+		err = os.Setenv(name, plain)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//EnvDevCreateSecret creates environment variable for the device, the value is
+//encrypted with kp before it is sent to resin.
+func EnvDevCreateSecret(ctx *Context, kp KeyProvider, id int64, key, value string) (*Env, error) {
+	enc, err := EncryptValue(kp, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return EnvDevCreate(ctx, id, key, enc)
+}
+
+//EnvAppCreateSecret creates environment variable for the application, the
+//value is encrypted with kp before it is sent to resin.
+func EnvAppCreateSecret(ctx *Context, kp KeyProvider, id int64, key, value string) (*AppEnv, error) {
+	enc, err := EncryptValue(kp, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return EnvAppCreate(ctx, id, key, enc)
+}
+
+// re-encrypts value with the current key of kp. It returns false if the value
+// is not encrypted or is already encrypted with the current key.
+func reencryptValue(kp KeyProvider, name, value string) (string, bool, error) {
+	if !IsSecret(value) {
+		return value, false, nil
+	}
+	id, err := SecretKeyID(value)
+	if err != nil {
+		return "", false, err
+	}
+	if id == kp.KeyID() {
+		return value, false, nil
+	}
+	plain, err := DecryptValue(kp, name, value)
+	if err != nil {
+		return "", false, err
+	}
+	enc, err := EncryptValue(kp, name, plain)
+	if err != nil {
+		return "", false, err
+	}
+	return enc, true, nil
+}
+
+//EnvAppRotateSecrets re-encrypts with the current key of kp all encrypted
+//variables of the application with the given appID and of its devices. kp must
+//still be able to unwrap the old keys. The number of updated variables is
+//returned.
+func EnvAppRotateSecrets(ctx *Context, appID int64, kp KeyProvider) (int, error) {
+	n := 0
+	envs, err := EnvAppGetAll(ctx, appID)
+	if err != nil {
+		return n, err
+	}
+	for _, e := range envs {
+		v, ok, err := reencryptValue(kp, e.Name, e.Value)
+		if err != nil {
+			return n, fmt.Errorf("resingo: rotating %s: %v", e.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		err = EnvAppUpdate(ctx, e.ID, v)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	devs, err := DevGetAllByApp(ctx, appID)
+	if err != nil {
+		if err == ErrDeviceNotFound {
+			return n, nil
+		}
+		return n, err
+	}
+	for _, d := range devs {
+		envs, err := EnvDevGetAll(ctx, d.ID)
+		if err != nil {
+			return n, err
+		}
+		for _, e := range envs {
+			v, ok, err := reencryptValue(kp, e.Name, e.Value)
+			if err != nil {
+				return n, fmt.Errorf("resingo: rotating %s of device %s: %v", e.Name, d.UUID, err)
+			}
+			if !ok {
+				continue
+			}
+			err = EnvDevUpdate(ctx, e.ID, v)
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
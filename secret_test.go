@@ -0,0 +1,118 @@
+package resingo
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resingo-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	oldKey, newKey := filepath.Join(dir, "old.key"), filepath.Join(dir, "new.key")
+	for _, k := range []string{oldKey, newKey} {
+		err = GenerateLocalKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if GenerateLocalKey(oldKey) == nil {
+		t.Error("expected an error when overwriting a key")
+	}
+	old, err := LoadLocalKeyProvider(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, value := "DB_PASSWORD", "s3cr3t\nwith new line"
+	enc, err := EncryptValue(old, name, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, SecretPrefix) || strings.Contains(enc, "s3cr3t") {
+		t.Fatalf("expected an encrypted value got %s", enc)
+	}
+	t.Run("Decrypt", func(ts *testing.T) {
+		plain, err := DecryptValue(old, name, enc)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if plain != value {
+			ts.Errorf("expected %q got %q", value, plain)
+		}
+		plain, err = DecryptValue(old, name, "not encrypted")
+		if err != nil || plain != "not encrypted" {
+			ts.Errorf("expected plain values to be kept got %q %v", plain, err)
+		}
+	})
+	t.Run("WrongName", func(ts *testing.T) {
+		_, err := DecryptValue(old, "OTHER", enc)
+		if err != ErrBadSecret {
+			ts.Errorf("expected %v got %v", ErrBadSecret, err)
+		}
+	})
+	t.Run("Tampered", func(ts *testing.T) {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(enc, SecretPrefix))
+		if err != nil {
+			ts.Fatal(err)
+		}
+		b[len(b)-1] ^= 1
+		_, err = DecryptValue(old, name, SecretPrefix+base64.StdEncoding.EncodeToString(b))
+		if err == nil {
+			ts.Error("expected an error for a tampered value")
+		}
+	})
+	t.Run("Rotate", func(ts *testing.T) {
+		kp, err := LoadLocalKeyProvider(newKey, oldKey)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		rotated, ok, err := reencryptValue(kp, name, enc)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if !ok {
+			ts.Fatal("expected the value to be re-encrypted")
+		}
+		id, err := SecretKeyID(rotated)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if id != kp.KeyID() {
+			ts.Errorf("expected key %s got %s", kp.KeyID(), id)
+		}
+		_, ok, err = reencryptValue(kp, name, rotated)
+		if err != nil || ok {
+			ts.Errorf("expected the value to be left untouched got %v %v", ok, err)
+		}
+
+		// the old provider doesn't know the new key
+		_, err = DecryptValue(old, name, rotated)
+		if err == nil {
+			ts.Error("expected an error for an unknown key")
+		}
+	})
+	t.Run("Environ", func(ts *testing.T) {
+		err := os.Setenv(name, enc)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		defer func() {
+			_ = os.Unsetenv(name)
+		}()
+		err = DecryptEnviron(old)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if v := os.Getenv(name); v != value {
+			ts.Errorf("expected %q got %q", value, v)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+package resingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+//ErrSupervisorReleaseNotFound is returned when there is no supervisor release
+//with the requested version for a device type.
+var ErrSupervisorReleaseNotFound = errors.New("resingo: supervisor release not found")
+
+//ErrSupervisorDowngrade is returned when setting a supervisor version that is
+//older than the version running on the device.
+var ErrSupervisorDowngrade = errors.New("resingo: supervisor can't be downgraded")
+
+//SupervisorRelease is a release of the resin supervisor for a device type.
+type SupervisorRelease struct {
+	ID         int64  `json:"id"`
+	Version    string `json:"supervisor_version"`
+	DeviceType string `json:"device_type"`
+	ImageName  string `json:"image_name"`
+	Note       string `json:"note"`
+	IsPublic   bool   `json:"is_public"`
+	Metadata   struct {
+		URI  string `json:"uri"`
+		Type string `json:"type"`
+	} `json:"__metadata"`
+}
+
+//SupervisorGetAll returns the supervisor releases for the device type, sorted
+//from the newest to the oldest.
+func SupervisorGetAll(ctx *Context, typ DeviceType) ([]*SupervisorRelease, error) {
+	reg, err := DevTypeGetRegistry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slug, err := reg.Resolve(string(typ))
+	if err != nil {
+		return nil, err
+	}
+	return supervisorGetAll(ctx, string(slug))
+}
+
+func supervisorGetAll(ctx *Context, slug string) ([]*SupervisorRelease, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("supervisor_release")
+	params := make(url.Values)
+	params.Set("filter", "device_type")
+	params.Set("eq", slug)
+	b, err := doJSON(ctx, "GET", uri, h, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res = struct {
+		D []*SupervisorRelease `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	sortSupervisorReleases(res.D)
+	return res.D, nil
+}
+
+//SupervisorGetAllByArch returns the supervisor releases for all device types
+//with the given architecture, sorted from the newest to the oldest.
+func SupervisorGetAllByArch(ctx *Context, arch string) ([]*SupervisorRelease, error) {
+	reg, err := DevTypeGetRegistry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	types := reg.ByArch(arch)
+	if len(types) == 0 {
+		return nil, fmt.Errorf("resingo: no device types with arch %s", arch)
+	}
+	var rst []*SupervisorRelease
+	for _, t := range types {
+		s, err := supervisorGetAll(ctx, t.Slug)
+		if err != nil {
+			return nil, err
+		}
+		rst = append(rst, s...)
+	}
+	sortSupervisorReleases(rst)
+	return rst, nil
+}
+
+// sorts the releases from the newest to the oldest, releases with bad
+// versions are put at the end.
+func sortSupervisorReleases(s []*SupervisorRelease) {
+	versions := make([]*Version, len(s))
+	for i, r := range s {
+		versions[i], _ = ParseVersion(r.Version)
+	}
+	sort.Sort(&supervisorSort{s, versions})
+}
+
+type supervisorSort struct {
+	s []*SupervisorRelease
+	v []*Version
+}
+
+func (s *supervisorSort) Len() int {
+	return len(s.s)
+}
+
+func (s *supervisorSort) Swap(i, j int) {
+	s.s[i], s.s[j] = s.s[j], s.s[i]
+	s.v[i], s.v[j] = s.v[j], s.v[i]
+}
+
+func (s *supervisorSort) Less(i, j int) bool {
+	a, b := s.v[i], s.v[j]
+	switch {
+	case a == nil:
+		return false
+	case b == nil:
+		return true
+	}
+	return a.Compare(b) > 0
+}
+
+//SupervisorGetLatest returns the newest supervisor release for the device
+//type.
+func SupervisorGetLatest(ctx *Context, typ DeviceType) (*SupervisorRelease, error) {
+	s, err := SupervisorGetAll(ctx, typ)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, ErrSupervisorReleaseNotFound
+	}
+	return s[0], nil
+}
+
+//SupervisorGetByVersion returns the supervisor release for the device type
+//with the given version.
+func SupervisorGetByVersion(ctx *Context, typ DeviceType, version string) (*SupervisorRelease, error) {
+	s, err := SupervisorGetAll(ctx, typ)
+	if err != nil {
+		return nil, err
+	}
+	return findSupervisorRelease(s, version)
+}
+
+func findSupervisorRelease(s []*SupervisorRelease, version string) (*SupervisorRelease, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range s {
+		rv, err := ParseVersion(r.Version)
+		if err != nil {
+			continue
+		}
+		if rv.Compare(v) == 0 {
+			return r, nil
+		}
+	}
+	return nil, ErrSupervisorReleaseNotFound
+}
+
+//DevSetSupervisor sets the supervisor version the device with the given uuid
+//should run. The supervisor can only be upgraded.
+func DevSetSupervisor(ctx *Context, uuid, version string) error {
+	dev, err := DevGetByUUID(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	r, err := SupervisorGetByVersion(ctx, DeviceType(dev.Type), version)
+	if err != nil {
+		return err
+	}
+	newer, err := supervisorIsNewer(dev, r.Version)
+	if err != nil {
+		return err
+	}
+	if newer {
+		return ErrSupervisorDowngrade
+	}
+	return devSetSupervisorRelease(ctx, dev.ID, r.ID)
+}
+
+// returns true if the device runs a supervisor newer than version.
+func supervisorIsNewer(dev *Device, version string) (bool, error) {
+	if dev.SuprevisorVersion == "" {
+		return false, nil
+	}
+	c, err := CompareVersions(dev.SuprevisorVersion, version)
+	if err != nil {
+		return false, err
+	}
+	return c > 0, nil
+}
+
+func devSetSupervisorRelease(ctx *Context, devID, releaseID int64) error {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(fmt.Sprintf("device(%d)", devID))
+	data := make(map[string]interface{})
+	data["should_be_managed_by__supervisor_release"] = releaseID
+	body, err := marhsalReader(data)
+	if err != nil {
+		return err
+	}
+	b, err := doJSON(ctx, "PATCH", uri, h, nil, body)
+	if err != nil {
+		return err
+	}
+	if string(b) != "OK" {
+		return errors.New("bad response")
+	}
+	return nil
+}
+
+//AppSetSupervisor sets the supervisor version of all devices of the
+//application with the given id. Devices that already run a newer supervisor
+//are skipped. The number of updated devices is returned.
+func AppSetSupervisor(ctx *Context, appID int64, version string) (int, error) {
+	devs, err := DevGetAllByApp(ctx, appID)
+	if err != nil {
+		if err == ErrDeviceNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	releases := make(map[string]*SupervisorRelease)
+	n := 0
+	for _, d := range devs {
+		newer, err := supervisorIsNewer(d, version)
+		if err != nil {
+			return n, err
+		}
+		if newer {
+			continue
+		}
+		r, ok := releases[d.Type]
+		if !ok {
+			r, err = SupervisorGetByVersion(ctx, DeviceType(d.Type), version)
+			if err != nil {
+				return n, fmt.Errorf("resingo: %s: %v", d.Type, err)
+			}
+			releases[d.Type] = r
+		}
+		err = devSetSupervisorRelease(ctx, d.ID, r.ID)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+//OutdatedSupervisor is a device that doesn't run the latest supervisor for its
+//device type.
+type OutdatedSupervisor struct {
+	Device  *Device
+	Current string
+	Latest  string
+}
+
+//AppGetOutdatedSupervisors returns the devices of the application with the
+//given id that run an older supervisor than the latest release for their
+//device type. Devices that never reported a supervisor version are not
+//included.
+func AppGetOutdatedSupervisors(ctx *Context, appID int64) ([]*OutdatedSupervisor, error) {
+	devs, err := DevGetAllByApp(ctx, appID)
+	if err != nil {
+		if err == ErrDeviceNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	latest := make(map[string]*SupervisorRelease)
+	var rst []*OutdatedSupervisor
+	for _, d := range devs {
+		if d.SuprevisorVersion == "" {
+			continue
+		}
+		r, ok := latest[d.Type]
+		if !ok {
+			r, err = SupervisorGetLatest(ctx, DeviceType(d.Type))
+			if err != nil {
+				return nil, fmt.Errorf("resingo: %s: %v", d.Type, err)
+			}
+			latest[d.Type] = r
+		}
+		c, err := CompareVersions(d.SuprevisorVersion, r.Version)
+		if err != nil {
+			return nil, err
+		}
+		if c < 0 {
+			rst = append(rst, &OutdatedSupervisor{
+				Device:  d,
+				Current: d.SuprevisorVersion,
+				Latest:  r.Version,
+			})
+		}
+	}
+	return rst, nil
+}
@@ -0,0 +1,172 @@
+package resingo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSupervisor is a fake resin API with supervisor releases and the devices
+// of application 1.
+type fakeSupervisor struct {
+	mu       sync.Mutex
+	releases []*SupervisorRelease
+	devices  []*Device
+	patches  map[string]string
+}
+
+func (f *fakeSupervisor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/supervisor_release":
+		var rst []*SupervisorRelease
+		for _, s := range f.releases {
+			if strings.Contains(r.URL.RawQuery, "'"+s.DeviceType+"'") {
+				rst = append(rst, s)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": rst})
+	case r.URL.Path == "/v1/device":
+		var rst []*Device
+		for _, d := range f.devices {
+			if strings.Contains(r.URL.RawQuery, d.UUID) {
+				rst = append(rst, d)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": rst})
+	case r.URL.Path == "/v1/application(1)":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"d": []interface{}{map[string]interface{}{"device": f.devices}},
+		})
+	case r.Method == "PATCH" && strings.HasPrefix(r.URL.Path, "/v1/device("):
+		b, _ := ioutil.ReadAll(r.Body)
+		f.patches[strings.TrimPrefix(r.URL.Path, "/v1/")] = string(b)
+		_, _ = w.Write([]byte("OK"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	fake := &fakeSupervisor{
+		releases: []*SupervisorRelease{
+			{ID: 1, Version: "v4.1.0", DeviceType: "raspberrypi3"},
+			{ID: 2, Version: "v6.0.1", DeviceType: "raspberrypi3"},
+			{ID: 3, Version: "v4.10.2", DeviceType: "raspberrypi3"},
+			{ID: 4, Version: "v6.0.1", DeviceType: "raspberrypi2"},
+			{ID: 5, Version: "v5.0.0", DeviceType: "beaglebone-black"},
+		},
+		devices: []*Device{
+			{ID: 10, UUID: "aaa", Type: "raspberrypi3", SuprevisorVersion: "4.1.0"},
+			{ID: 11, UUID: "bbb", Type: "raspberrypi3", SuprevisorVersion: "6.0.1"},
+			{ID: 12, UUID: "ccc", Type: "raspberrypi2", SuprevisorVersion: "4.10.2"},
+			{ID: 13, UUID: "ddd", Type: "raspberrypi2"},
+		},
+		patches: make(map[string]string),
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	ctx := &Context{
+		Client: &http.Client{},
+		Config: &Config{ResinEndpoint: ts.URL},
+		ConfigCache: &ConfigCache{
+			cfg: &ResinConfig{
+				DeviceTypes: []DeviceTypeInfo{
+					{Slug: "raspberrypi3", Name: "Raspberry Pi 3", Arch: "armv7hf"},
+					{Slug: "raspberrypi2", Name: "Raspberry Pi 2", Arch: "armv7hf"},
+					{Slug: "beaglebone-black", Name: "BeagleBone Black", Arch: "armv7hf"},
+					{Slug: "intel-nuc", Name: "Intel NUC", Arch: "amd64"},
+				},
+			},
+			fetched: time.Now(),
+		},
+	}
+	t.Run("GetAll", func(ts *testing.T) {
+		s, err := SupervisorGetAll(ctx, RaspberryPi3)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		var versions []string
+		for _, r := range s {
+			versions = append(versions, r.Version)
+		}
+		if strings.Join(versions, ",") != "v6.0.1,v4.10.2,v4.1.0" {
+			ts.Errorf("expected newest first got %v", versions)
+		}
+		s, err = SupervisorGetAllByArch(ctx, "armv7hf")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if len(s) != 5 {
+			ts.Errorf("expected 5 releases got %d", len(s))
+		}
+		_, err = SupervisorGetAllByArch(ctx, "i386")
+		if err == nil {
+			ts.Error("expected an error")
+		}
+		r, err := SupervisorGetLatest(ctx, RaspberryPi3)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if r.ID != 2 {
+			ts.Errorf("expected release 2 got %d", r.ID)
+		}
+		_, err = SupervisorGetByVersion(ctx, RaspberryPi3, "5.0.0")
+		if err != ErrSupervisorReleaseNotFound {
+			ts.Errorf("expected %v got %v", ErrSupervisorReleaseNotFound, err)
+		}
+	})
+	t.Run("Set", func(ts *testing.T) {
+		err := DevSetSupervisor(ctx, "aaa", "4.10.2")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		p := fake.patches["device(10)"]
+		if !strings.Contains(p, `"should_be_managed_by__supervisor_release":3`) {
+			ts.Errorf("unexpected patch %s", p)
+		}
+		err = DevSetSupervisor(ctx, "bbb", "v4.10.2")
+		if err != ErrSupervisorDowngrade {
+			ts.Errorf("expected %v got %v", ErrSupervisorDowngrade, err)
+		}
+	})
+	t.Run("App", func(ts *testing.T) {
+		fake.patches = make(map[string]string)
+		n, err := AppSetSupervisor(ctx, 1, "6.0.1")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if n != 4 {
+			ts.Errorf("expected 4 devices got %d", n)
+		}
+		if !strings.Contains(fake.patches["device(12)"], ":4") {
+			ts.Errorf("expected the raspberrypi2 release got %s", fake.patches["device(12)"])
+		}
+		_, err = AppSetSupervisor(ctx, 1, "4.1.0")
+		if err == nil {
+			ts.Error("expected an error for the missing raspberrypi2 release")
+		}
+	})
+	t.Run("Outdated", func(ts *testing.T) {
+		o, err := AppGetOutdatedSupervisors(ctx, 1)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		var uuids []string
+		for _, d := range o {
+			uuids = append(uuids, d.Device.UUID)
+			if d.Latest != "v6.0.1" {
+				ts.Errorf("expected latest v6.0.1 got %s", d.Latest)
+			}
+		}
+		if strings.Join(uuids, ",") != "aaa,ccc" {
+			ts.Errorf("expected aaa and ccc to be outdated got %v", uuids)
+		}
+	})
+}
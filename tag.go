@@ -0,0 +1,193 @@
+package resingo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+//DevTag is a key value tag attached to a device.
+type DevTag struct {
+	ID     int64  `json:"id"`
+	Key    string `json:"tag_key"`
+	Value  string `json:"value"`
+	Device struct {
+		ID       int64 `json:"__id"`
+		Deferred struct {
+			URI string `json:"uri"`
+		} `json:"__deferred"`
+	} `json:"device"`
+	Metadata struct {
+		URI  string `json:"uri"`
+		Type string `json:"type"`
+	} `json:"__metadata"`
+}
+
+//AppTag is a key value tag attached to an application.
+type AppTag struct {
+	ID          int64  `json:"id"`
+	Key         string `json:"tag_key"`
+	Value       string `json:"value"`
+	Application struct {
+		ID       int64 `json:"__id"`
+		Deferred struct {
+			URI string `json:"uri"`
+		} `json:"__deferred"`
+	} `json:"application"`
+	Metadata struct {
+		URI  string `json:"uri"`
+		Type string `json:"type"`
+	} `json:"__metadata"`
+}
+
+//TagDevGetAll returns all tags of the device with the given id.
+func TagDevGetAll(ctx *Context, id int64) ([]*DevTag, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("device_tag")
+	param := make(url.Values)
+	param.Set("filter", "device")
+	param.Set("eq", fmt.Sprint(id))
+	b, err := doJSON(ctx, "GET", uri, h, param, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := struct {
+		D []*DevTag `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.D, nil
+}
+
+//TagDevSet sets the tag key of the device with the given id to value. The tag
+//is created if the device doesn't have it yet.
+func TagDevSet(ctx *Context, id int64, key, value string) (*DevTag, error) {
+	tags, err := TagDevGetAll(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		if t.Key == key {
+			if t.Value != value {
+				err = resourceSetValue(ctx, fmt.Sprintf("device_tag(%d)", t.ID), value)
+				if err != nil {
+					return nil, err
+				}
+				t.Value = value
+			}
+			return t, nil
+		}
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("device_tag")
+	data := make(map[string]interface{})
+	data["device"] = id
+	data["tag_key"] = key
+	data["value"] = value
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "POST", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	t := &DevTag{}
+	err = json.Unmarshal(b, t)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+//TagDevDelete deletes the device tag with the given id.
+func TagDevDelete(ctx *Context, id int64) error {
+	return resourceDelete(ctx, fmt.Sprintf("device_tag(%d)", id))
+}
+
+//TagAppGetAll returns all tags of the application with the given id.
+func TagAppGetAll(ctx *Context, id int64) ([]*AppTag, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("application_tag")
+	param := make(url.Values)
+	param.Set("filter", "application")
+	param.Set("eq", fmt.Sprint(id))
+	b, err := doJSON(ctx, "GET", uri, h, param, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := struct {
+		D []*AppTag `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.D, nil
+}
+
+//TagAppSet sets the tag key of the application with the given id to value.
+//The tag is created if the application doesn't have it yet.
+func TagAppSet(ctx *Context, id int64, key, value string) (*AppTag, error) {
+	tags, err := TagAppGetAll(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		if t.Key == key {
+			if t.Value != value {
+				err = resourceSetValue(ctx, fmt.Sprintf("application_tag(%d)", t.ID), value)
+				if err != nil {
+					return nil, err
+				}
+				t.Value = value
+			}
+			return t, nil
+		}
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint("application_tag")
+	data := make(map[string]interface{})
+	data["application"] = id
+	data["tag_key"] = key
+	data["value"] = value
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "POST", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	t := &AppTag{}
+	err = json.Unmarshal(b, t)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+//TagAppDelete deletes the application tag with the given id.
+func TagAppDelete(ctx *Context, id int64) error {
+	return resourceDelete(ctx, fmt.Sprintf("application_tag(%d)", id))
+}
+
+//DevGetAllByTag returns all devices that have the tag key set to value. When
+//value is empty, all devices with the tag are returned regardless of the
+//value. It is a shortcut for DevGetAll with DevFilterTag, use DevIter to page
+//through many devices.
+//
+//	// all devices in berlin
+//	devs, err := DevGetAllByTag(ctx, "site", "berlin")
+func DevGetAllByTag(ctx *Context, key, value string) ([]*Device, error) {
+	return DevGetAll(ctx, DevFilterTag(key, value))
+}
+
+//AppGetAllByTag returns all applications that have the tag key set to value.
+//When value is empty, all applications with the tag are returned regardless of
+//the value. It is a shortcut for AppGetAll with AppFilterTag.
+func AppGetAllByTag(ctx *Context, key, value string) ([]*Application, error) {
+	return AppGetAll(ctx, AppFilterTag(key, value))
+}
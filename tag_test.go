@@ -0,0 +1,98 @@
+package resingo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTag(t *testing.T) {
+	config := &Config{
+		Username:      ENV.Username,
+		Password:      ENV.Password,
+		ResinEndpoint: apiEndpoint,
+	}
+	client := &http.Client{}
+	ctx := &Context{
+		Client: client,
+		Config: config,
+	}
+	err := Login(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appName := "tag_test"
+	app, err := AppCreate(ctx, appName, RaspberryPi3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = AppDelete(ctx, app.ID)
+	}()
+	sites := []string{"berlin", "berlin", "dar"}
+	devices := make([]*Device, len(sites))
+	for i := range sites {
+		uuid, err := GenerateUUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		devices[i], err = DevRegister(ctx, appName, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("DevSet", func(ts *testing.T) {
+		for i, s := range sites {
+			tag, err := TagDevSet(ctx, devices[i].ID, "site", s)
+			if err != nil {
+				ts.Fatal(err)
+			}
+			if tag.Value != s {
+				ts.Errorf("expected %s got %s", s, tag.Value)
+			}
+		}
+	})
+	t.Run("DevGetAllByTag", func(ts *testing.T) {
+		devs, err := DevGetAllByTag(ctx, "site", "berlin")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if len(devs) != 2 {
+			ts.Errorf("expected 2 devices got %d", len(devs))
+		}
+	})
+	t.Run("DevDelete", func(ts *testing.T) {
+		tags, err := TagDevGetAll(ctx, devices[0].ID)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		for _, tag := range tags {
+			err = TagDevDelete(ctx, tag.ID)
+			if err != nil {
+				ts.Error(err)
+			}
+		}
+	})
+	t.Run("App", func(ts *testing.T) {
+		_, err := TagAppSet(ctx, app.ID, "customer", "acme")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		apps, err := AppGetAllByTag(ctx, "customer", "acme")
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if len(apps) != 1 || apps[0].ID != app.ID {
+			ts.Errorf("expected application %d got %v", app.ID, apps)
+		}
+		tags, err := TagAppGetAll(ctx, app.ID)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		for _, tag := range tags {
+			err = TagAppDelete(ctx, tag.ID)
+			if err != nil {
+				ts.Error(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package resingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/guregu/null"
+)
+
+//ErrUserNotFound is returned when there is no user matching the query.
+var ErrUserNotFound = errors.New("resingo: user not found")
+
+//User a resin user
+//
+// Resources like devices and applications reference the user as a deferred
+// object, in which case only ID and Metadata are set. Use UserGetByID or
+// UserGetMe to retrieve the rest of the fields.
+type User struct {
+	ID        int64     `json:"__id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Company   string    `json:"company"`
+	Actor     int64     `json:"actor"`
+	CreatedAt null.Time `json:"created_at"`
+	Metadata  struct {
+		URI string `json:"uri"`
+	} `json:"__deferred"`
+}
+
+//UnmarshalJSON implements json.Unmarshaler. The user id is sent as __id when
+//the user is deferred and as id when the user resource is queried directly,
+//both are supported.
+func (u *User) UnmarshalJSON(b []byte) error {
+	type user User
+	v := struct {
+		*user
+		ID int64 `json:"id"`
+	}{user: (*user)(u)}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	if v.ID != 0 {
+		u.ID = v.ID
+	}
+	return nil
+}
+
+//UserProfile are the user details that can be changed with
+//UserUpdateProfile. Empty fields are left untouched.
+type UserProfile struct {
+	FirstName string
+	LastName  string
+	Company   string
+}
+
+//UserWhoami returns the basic details(id, username and email) of the user who
+//authorized ctx.
+func UserWhoami(ctx *Context) (*User, error) {
+	return userWhoami(ctx, ctx.Config.AuthToken)
+}
+
+func userWhoami(ctx *Context, token string) (*User, error) {
+	h := authHeader(token)
+	uri := apiEndpoint + "/user/v1/whoami"
+	b, err := doJSON(ctx, "GET", uri, h, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	u := &User{}
+	err = json.Unmarshal(b, u)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+//UserGetEmail returns the email of the user who authorized ctx.
+func UserGetEmail(ctx *Context) (string, error) {
+	u, err := UserWhoami(ctx)
+	if err != nil {
+		return "", err
+	}
+	return u.Email, nil
+}
+
+//UserGetByID returns the user with the given id.
+func UserGetByID(ctx *Context, id int64) (*User, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(fmt.Sprintf("user(%d)", id))
+	b, err := doJSON(ctx, "GET", uri, h, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res = struct {
+		D []*User `json:"d"`
+	}{}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.D) > 0 {
+		return res.D[0], nil
+	}
+	return nil, ErrUserNotFound
+}
+
+//UserGetMe returns the user who authorized ctx.
+//
+// The user resource doesn't expose the email, so this combines the results of
+// UserWhoami and UserGetByID.
+func UserGetMe(ctx *Context) (*User, error) {
+	me, err := UserWhoami(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u, err := UserGetByID(ctx, me.ID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Email == "" {
+		u.Email = me.Email
+	}
+	if u.Username == "" {
+		u.Username = me.Username
+	}
+	return u, nil
+}
+
+//UserUpdateProfile updates the profile of the user with the given id and
+//returns the updated user.
+func UserUpdateProfile(ctx *Context, id int64, p *UserProfile) (*User, error) {
+	h := authHeader(ctx.Config.AuthToken)
+	uri := ctx.Config.APIEndpoint(fmt.Sprintf("user(%d)", id))
+	data := make(map[string]interface{})
+	if p.FirstName != "" {
+		data["first_name"] = p.FirstName
+	}
+	if p.LastName != "" {
+		data["last_name"] = p.LastName
+	}
+	if p.Company != "" {
+		data["company"] = p.Company
+	}
+	if len(data) == 0 {
+		return UserGetByID(ctx, id)
+	}
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "PATCH", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) != "OK" {
+		return nil, errors.New("bad response")
+	}
+	return UserGetByID(ctx, id)
+}
+
+//UserRegister creates a new resin account and returns the newly registered
+//user.
+//
+// This doesn't change the session of ctx, the new user should be authenticated
+// with Login before it can be used.
+func UserRegister(ctx *Context, username, email, password string) (*User, error) {
+	if email == "" || password == "" {
+		return nil, ErrMissingCredentials
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := apiEndpoint + "/user/register"
+	data := make(map[string]interface{})
+	data["email"] = email
+	data["password"] = password
+	if username != "" {
+		data["username"] = username
+	}
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doJSON(ctx, "POST", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	tok := string(b)
+	if !ValidToken(tok) {
+		return nil, ErrBadToken
+	}
+	return userWhoami(ctx, tok)
+}
+
+//UserChangePassword changes the password of the user who authorized ctx and
+//returns the user. If ctx was configured with a password, it is updated to
+//the new one so that subsequent logins with Credentials keep working.
+func UserChangePassword(ctx *Context, current, newPassword string) (*User, error) {
+	if current == "" || newPassword == "" {
+		return nil, ErrMissingCredentials
+	}
+	h := authHeader(ctx.Config.AuthToken)
+	uri := apiEndpoint + "/user/v1/password"
+	data := make(map[string]interface{})
+	data["currentPassword"] = current
+	data["newPassword"] = newPassword
+	body, err := marhsalReader(data)
+	if err != nil {
+		return nil, err
+	}
+	_, err = doJSON(ctx, "POST", uri, h, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Config.Password != "" {
+		ctx.Config.Password = newPassword
+	}
+	return UserGetMe(ctx)
+}
@@ -0,0 +1,151 @@
+package resingo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUser(t *testing.T) {
+	config := &Config{
+		Username:      ENV.Username,
+		Password:      ENV.Password,
+		ResinEndpoint: apiEndpoint,
+	}
+	client := &http.Client{}
+	ctx := &Context{
+		Client: client,
+		Config: config,
+	}
+	err := Login(ctx, Credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Whoami", func(ts *testing.T) {
+		u, err := UserWhoami(ctx)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if u.ID != ctx.Config.UserID() {
+			ts.Errorf("expected %d got %d", ctx.Config.UserID(), u.ID)
+		}
+	})
+	t.Run("GetEmail", func(ts *testing.T) {
+		email, err := UserGetEmail(ctx)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if ENV.Email != "" && email != ENV.Email {
+			ts.Errorf("expected %s got %s", ENV.Email, email)
+		}
+	})
+	t.Run("GetMe", func(ts *testing.T) {
+		u, err := UserGetMe(ctx)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if u.Username != ctx.Config.Username {
+			ts.Errorf("expected %s got %s", ctx.Config.Username, u.Username)
+		}
+	})
+	t.Run("UpdateProfile", func(ts *testing.T) {
+		u, err := UserUpdateProfile(ctx, ctx.Config.UserID(), &UserProfile{
+			Company: "resingo",
+		})
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if u.Company != "resingo" {
+			ts.Errorf("expected resingo got %s", u.Company)
+		}
+	})
+	t.Run("Register", func(ts *testing.T) {
+		if ENV.Register.Email == "" || ENV.Register.Password == "" {
+			ts.Skip("missing RESINTEST_REGISTER_EMAIL or RESINTEST_REGISTER_PASSWORD")
+		}
+		u, err := UserRegister(ctx, ENV.Register.Username,
+			ENV.Register.Email, ENV.Register.Password)
+		if err != nil {
+			ts.Fatal(err)
+		}
+		if u.Email != ENV.Register.Email {
+			ts.Errorf("expected %s got %s", ENV.Register.Email, u.Email)
+		}
+	})
+}
+
+func TestUserUnmarshal(t *testing.T) {
+	sample := []struct {
+		src    string
+		expect int64
+	}{
+		{`{"__id":12,"__deferred":{"uri":"/resin/user(12)"}}`, 12},
+		{`{"id":14,"username":"gernest"}`, 14},
+	}
+	for _, v := range sample {
+		u := &User{}
+		err := json.Unmarshal([]byte(v.src), u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != v.expect {
+			t.Errorf("expected %d got %d", v.expect, u.ID)
+		}
+	}
+}
+
+func TestUserChangePassword(t *testing.T) {
+	var sent map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/user/v1/password":
+			sent = nil
+			_ = json.NewDecoder(r.Body).Decode(&sent)
+			if sent["currentPassword"] != "old" {
+				http.Error(w, "wrong password", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, "OK")
+		case r.URL.Path == "/user/v1/whoami":
+			fmt.Fprint(w, `{"id":3,"username":"gernest","email":"g@example.com"}`)
+		case r.URL.Path == "/v1/user(3)":
+			fmt.Fprint(w, `{"d":[{"__id":3,"username":"gernest"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	ctx := &Context{
+		Client: &http.Client{Transport: &rewriteTransport{target: target}},
+		Config: &Config{ResinEndpoint: apiEndpoint, Username: "gernest", Password: "old"},
+	}
+	u, err := UserChangePassword(ctx, "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent["currentPassword"] != "old" || sent["newPassword"] != "new" {
+		t.Errorf("expected the current and new password got %v", sent)
+	}
+	if u.ID != 3 || u.Email != "g@example.com" {
+		t.Errorf("expected the user got %+v", u)
+	}
+	if ctx.Config.Password != "new" {
+		t.Errorf("expected the configured password to be updated got %s", ctx.Config.Password)
+	}
+
+	_, err = UserChangePassword(ctx, "wrong", "newer")
+	if err == nil || !strings.Contains(err.Error(), "[401 ]") {
+		t.Errorf("expected an unauthorized error got %v", err)
+	}
+	if ctx.Config.Password != "new" {
+		t.Errorf("expected the configured password to be kept got %s", ctx.Config.Password)
+	}
+	if _, err = UserChangePassword(ctx, "", "new"); err != ErrMissingCredentials {
+		t.Errorf("expected %v got %v", ErrMissingCredentials, err)
+	}
+}
@@ -0,0 +1,191 @@
+package resingo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Version is a resin OS or supervisor version. Versions follow semver, resin
+//adds a revision to the build metadata of OS versions, for instance
+//2.0.6+rev3 is the third revision of 2.0.6.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string
+	Build      []string
+
+	// Rev is the revision from the build metadata, 3 for 2.0.6+rev3.
+	Rev int
+
+	// Variant is dev or prod when the version has it, either in the build
+	// metadata(2.0.6+rev3.dev) or in parenthesis(2.0.6+rev3 (prod)).
+	Variant string
+}
+
+//ParseVersion parses a version string. The version strings reported by devices
+//are accepted as well, like "Resin OS 2.0.6+rev3" and "v6.0.1". Old style
+//revisions like 2.0.0.rev1 are also supported.
+func ParseVersion(s string) (*Version, error) {
+	v := &Version{}
+	src := s
+	s = strings.TrimSpace(s)
+	for _, p := range []string{"Resin OS ", "resinOS ", "Resin OS", "resinOS"} {
+		if strings.HasPrefix(s, p) {
+			s = strings.TrimSpace(s[len(p):])
+			break
+		}
+	}
+	if i := strings.IndexRune(s, '('); i != -1 && strings.HasSuffix(s, ")") {
+		v.Variant = strings.TrimSpace(s[i+1 : len(s)-1])
+		s = strings.TrimSpace(s[:i])
+	}
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.Index(s, ".rev"); i != -1 && !strings.ContainsRune(s, '+') {
+		s = s[:i] + "+" + s[i+1:]
+	}
+	if i := strings.IndexRune(s, '+'); i != -1 {
+		v.Build = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	if i := strings.IndexRune(s, '-'); i != -1 {
+		v.Prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("resingo: bad version %q", src)
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("resingo: bad version %q", src)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	for _, b := range v.Build {
+		switch {
+		case strings.HasPrefix(b, "rev"):
+			n, err := strconv.Atoi(b[3:])
+			if err != nil {
+				return nil, fmt.Errorf("resingo: bad version %q", src)
+			}
+			v.Rev = n
+		case b == "dev" || b == "prod":
+			v.Variant = b
+		}
+	}
+	return v, nil
+}
+
+//String returns the version without the variant.
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if len(v.Build) > 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+	return s
+}
+
+//Compare returns -1, 0 or 1 when v is older, the same or newer than o. Semver
+//precedence is used, but unlike semver the revision is compared too. The
+//variant is ignored.
+func (v *Version) Compare(o *Version) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	if c := comparePrerelease(v.Prerelease, o.Prerelease); c != 0 {
+		return c
+	}
+	return compareInt(v.Rev, o.Rev)
+}
+
+//CompareVersions parses and compares two version strings, see
+//Version.Compare.
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+//SortVersions sorts the version strings from the newest to the oldest. Strings
+//that are not valid versions are put at the end.
+func SortVersions(versions []string) {
+	parsed := make(map[string]*Version)
+	for _, s := range versions {
+		if v, err := ParseVersion(s); err == nil {
+			parsed[s] = v
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := parsed[versions[i]], parsed[versions[j]]
+		switch {
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		}
+		return a.Compare(b) > 0
+	})
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compares pre release identifiers as defined by semver, a version without pre
+// release identifiers is newer than one with them.
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if c := compareInt(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(a), len(b))
+}
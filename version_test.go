@@ -0,0 +1,80 @@
+package resingo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	sample := []struct {
+		src     string
+		version string
+		rev     int
+		variant string
+	}{
+		{"2.0.6+rev3", "2.0.6+rev3", 3, ""},
+		{"Resin OS 2.0.6+rev3", "2.0.6+rev3", 3, ""},
+		{"Resin OS 2.0.6+rev3 (prod)", "2.0.6+rev3", 3, "prod"},
+		{"2.0.6+rev3.dev", "2.0.6+rev3.dev", 3, "dev"},
+		{"Resin OS 2.0.0.rev1", "2.0.0+rev1", 1, ""},
+		{"v6.0.1", "6.0.1", 0, ""},
+		{"1.24", "1.24.0", 0, ""},
+		{"2.0.0-beta.3+rev1", "2.0.0-beta.3+rev1", 1, ""},
+	}
+	for _, v := range sample {
+		ver, err := ParseVersion(v.src)
+		if err != nil {
+			t.Fatalf("%s: %v", v.src, err)
+		}
+		if ver.String() != v.version {
+			t.Errorf("%s: expected %s got %s", v.src, v.version, ver)
+		}
+		if ver.Rev != v.rev {
+			t.Errorf("%s: expected rev %d got %d", v.src, v.rev, ver.Rev)
+		}
+		if ver.Variant != v.variant {
+			t.Errorf("%s: expected variant %s got %s", v.src, v.variant, ver.Variant)
+		}
+	}
+	for _, v := range []string{"", "2", "a.b.c", "1.2.3.4", "2.0.6+revx"} {
+		_, err := ParseVersion(v)
+		if err == nil {
+			t.Errorf("expected an error for %q", v)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	sample := []struct {
+		a, b string
+		cmp  int
+	}{
+		{"2.0.6+rev3", "2.0.6+rev3", 0},
+		{"2.0.6+rev3", "2.0.6+rev2", 1},
+		{"2.0.6+rev3", "2.0.7+rev1", -1},
+		{"2.0.6", "2.0.6+rev1", -1},
+		{"Resin OS 2.0.6+rev3 (prod)", "2.0.6+rev3.dev", 0},
+		{"2.10.0", "2.9.9", 1},
+		{"1.24.0", "2.0.0", -1},
+		{"2.0.0-beta.2", "2.0.0", -1},
+		{"2.0.0-beta.2", "2.0.0-beta.11", -1},
+		{"2.0.0-beta", "2.0.0-alpha.1", 1},
+		{"2.0.0-beta", "2.0.0-beta.1", -1},
+		{"2.0.0-1", "2.0.0-beta", -1},
+	}
+	for _, v := range sample {
+		c, err := CompareVersions(v.a, v.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != v.cmp {
+			t.Errorf("%s %s: expected %d got %d", v.a, v.b, v.cmp, c)
+		}
+	}
+	versions := []string{"2.0.0+rev1", "bad", "2.0.6+rev3", "1.24.0", "2.0.6+rev1"}
+	SortVersions(versions)
+	expect := []string{"2.0.6+rev3", "2.0.6+rev1", "2.0.0+rev1", "1.24.0", "bad"}
+	if !reflect.DeepEqual(versions, expect) {
+		t.Errorf("expected %v got %v", expect, versions)
+	}
+}